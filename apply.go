@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"gopkg.in/yaml.v3"
+)
+
+// Apply reconciles the running sessions of configured projects, including
+// sessions started in a git worktree. When a previous configuration is given,
+// only sessions for projects that changed are reconciled.
+func Apply(
+	ctx context.Context,
+	runner Runner,
+	config MuxifyConfiguration,
+	previous *MuxifyConfiguration,
+	profile string,
+) error {
+	sessions, err := runner.GetRunningSessions(ctx)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, session := range sessions {
+		projectName, branch, isWorktree := config.sessionProjectName(session.Name)
+		project, ok := config.GetProject(projectName)
+		if !ok {
+			continue
+		}
+		if previous != nil {
+			if previousProject, ok := previous.GetProject(projectName); ok &&
+				!projectChanged(previousProject, project) {
+				continue
+			}
+		}
+		project.Profile = profile
+		if isWorktree {
+			if project, err = project.ForWorktree(branch); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", session.Name, err))
+				continue
+			}
+		}
+		if project, err = project.Resolve(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", session.Name, err))
+			continue
+		}
+		slog.Info("Applying configuration", "session", session.Name)
+		if err = runner.Run(ctx, project); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", session.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// projectChanged compares the configuration of two projects. The internal
+// window ids are generated when decoding, so they are ignored.
+func projectChanged(a Project, b Project) bool {
+	aYaml, errA := yaml.Marshal(a)
+	bYaml, errB := yaml.Marshal(b)
+	return errA != nil || errB != nil || !bytes.Equal(aYaml, bYaml)
+}
+
+// watchDebounce is the time to wait for more file system events before
+// reloading, as editors often write a file in multiple steps.
+const watchDebounce = 200 * time.Millisecond
+
+// WatchConfiguration applies the configuration to running sessions, and
+// reapplies it whenever a configuration file changes, until the context is
+// cancelled. Each time is limited by the timeout. Errors are displayed in the
+// tmux status line rather than stopping the watcher.
+func WatchConfiguration(
+	ctx context.Context,
+	os OS,
+	runner Runner,
+	profile string,
+	timeout time.Duration,
+) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+	configDir, err := getAppConfigDirPath(os)
+	if err != nil {
+		return err
+	}
+	dropInDir := filepath.Join(configDir, "projects.d")
+
+	var previous *MuxifyConfiguration
+	var files []string
+	reload := func() {
+		var config MuxifyConfiguration
+		var err error
+		config, files, err = ReadConfigurationFiles(os)
+		for _, dir := range watchedDirs(configDir, dropInDir, files) {
+			if !slices.Contains(watcher.WatchList(), dir) {
+				if err := watcher.Add(dir); err != nil {
+					slog.Debug("Cannot watch directory", "dir", dir, "err", err)
+				}
+			}
+		}
+		if err == nil {
+			applyCtx, cancel := withTimeout(ctx, timeout)
+			err = Apply(applyCtx, runner, config, previous, profile)
+			cancel()
+		}
+		// Failed sessions are retried on the next change
+		if err == nil {
+			previous = &config
+		}
+		if err != nil && ctx.Err() == nil {
+			slog.Error("Error applying configuration", "err", err)
+			if displayErr := runner.DisplayMessage(ctx, "muxify: "+err.Error()); displayErr != nil {
+				slog.Error("Error displaying message", "err", displayErr)
+			}
+		}
+	}
+	reload()
+
+	timer := time.NewTimer(watchDebounce)
+	timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			// Creating projects.d reloads, which starts watching it
+			if filepath.Clean(event.Name) == dropInDir ||
+				isConfigurationFile(event.Name, configDir, dropInDir, files) {
+				timer.Reset(watchDebounce)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			slog.Error("Error watching configuration", "err", err)
+		case <-timer.C:
+			reload()
+		}
+	}
+}
+
+func watchedDirs(configDir string, dropInDir string, files []string) []string {
+	dirs := []string{configDir, dropInDir}
+	for _, file := range files {
+		if dir := filepath.Dir(file); !slices.Contains(dirs, dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+func isConfigurationFile(name string, configDir string, dropInDir string, files []string) bool {
+	dir, file := filepath.Split(filepath.Clean(name))
+	dir = filepath.Clean(dir)
+	ext := filepath.Ext(file)
+	return slices.Contains(configurationFormats, ext) &&
+		((dir == configDir && file == "projects"+ext) || dir == dropInDir) ||
+		slices.Contains(files, name)
+}
@@ -0,0 +1,225 @@
+package main_test
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/mock/gomock"
+
+	. "github.com/stroiman/muxify"
+)
+
+type ApplyTestSuite struct {
+	GomegaSuite
+	controller *gomock.Controller
+	runner     *MockRunner
+	applied    []string
+}
+
+func TestApply(t *testing.T) {
+	suite.Run(t, new(ApplyTestSuite))
+}
+
+func (s *ApplyTestSuite) SetupTest() {
+	s.GomegaSuite.SetupTest()
+	s.controller = gomock.NewController(s.T())
+	s.runner = NewMockRunner(s.controller)
+	s.applied = nil
+	s.runner.EXPECT().GetRunningSessions(gomock.Any()).Return(TmuxSessions{
+		{Name: "project-1"},
+		{Name: "project-2"},
+		{Name: "unknown"},
+		{Name: "me@work"},
+	}, nil).AnyTimes()
+	s.runner.EXPECT().Run(gomock.Any(), gomock.Any()).Do(func(_ context.Context, p Project) {
+		s.applied = append(s.applied, p.Name+":"+p.Profile)
+	}).AnyTimes()
+}
+
+func (s *ApplyTestSuite) TearDownTest() {
+	s.controller.Finish()
+}
+
+func (s *ApplyTestSuite) decode(config string) MuxifyConfiguration {
+	result, err := Decode(strings.NewReader(config))
+	s.Expect(err).ToNot(HaveOccurred())
+	return result
+}
+
+var applyConfig = `
+projects:
+  - name: project-1
+    windows:
+      - name: Editor
+  - name: project-2
+  - name: project-3
+`
+
+func (s *ApplyTestSuite) TestApplyToAllRunningProjects() {
+	s.Expect(Apply(context.Background(), s.runner, s.decode(applyConfig), nil, "office")).To(Succeed())
+	s.Expect(s.applied).To(HaveExactElements("project-1:office", "project-2:office"))
+}
+
+func (s *ApplyTestSuite) TestApplyToProjectNamedLikeWorktreeSession() {
+	config := s.decode("projects:\n  - name: me@work")
+	s.Expect(Apply(context.Background(), s.runner, config, nil, "")).To(Succeed())
+	s.Expect(s.applied).To(HaveExactElements("me@work:"))
+}
+
+func (s *ApplyTestSuite) TestApplyOnlyChangedProjects() {
+	previous := s.decode(applyConfig)
+	current := s.decode(strings.Replace(applyConfig, "Editor", "Code", 1))
+	s.Expect(Apply(context.Background(), s.runner, current, &previous, "")).To(Succeed())
+	s.Expect(s.applied).To(HaveExactElements("project-1:"))
+}
+
+func (s *ApplyTestSuite) TestUnchangedConfigurationAppliesNothing() {
+	previous := s.decode(applyConfig)
+	s.Expect(Apply(context.Background(), s.runner, s.decode(applyConfig), &previous, "")).To(Succeed())
+	s.Expect(s.applied).To(BeEmpty())
+}
+
+func (s *ApplyTestSuite) TestErrorsDoNotStopOtherProjects() {
+	runner := NewMockRunner(s.controller)
+	runner.EXPECT().GetRunningSessions(gomock.Any()).Return(TmuxSessions{
+		{Name: "project-1"},
+		{Name: "project-2"},
+	}, nil)
+	runner.EXPECT().Run(gomock.Any(), projectNamed("project-1")).Return(errors.New("Failure"))
+	runner.EXPECT().Run(gomock.Any(), projectNamed("project-2"))
+	s.Expect(
+		Apply(context.Background(), runner, s.decode(applyConfig), nil, ""),
+	).To(MatchError(ContainSubstring("project-1: Failure")))
+}
+
+func projectNamed(name string) gomock.Matcher {
+	return gomock.Cond(func(x any) bool { return x.(Project).Name == name })
+}
+
+// configDirOS reads configuration files from disk, with a fake environment
+type configDirOS struct {
+	env map[string]string
+}
+
+func (o configDirOS) Dir(name string) fs.FS {
+	return os.DirFS(name)
+}
+
+func (o configDirOS) LookupEnv(name string) (string, bool) {
+	value, ok := o.env[name]
+	return value, ok
+}
+
+type WatchConfigurationTestSuite struct {
+	GomegaSuite
+	configDir string
+	runner    *MockRunner
+	mutex     sync.Mutex
+	applied   []string
+	failures  map[string]error
+	cancel    context.CancelFunc
+	done      chan error
+}
+
+func TestWatchConfiguration(t *testing.T) {
+	suite.Run(t, new(WatchConfigurationTestSuite))
+}
+
+func (s *WatchConfigurationTestSuite) SetupTest() {
+	s.GomegaSuite.SetupTest()
+	dir := s.T().TempDir()
+	s.configDir = filepath.Join(dir, "muxify")
+	s.Expect(os.MkdirAll(s.configDir, 0700)).To(Succeed())
+	s.applied = nil
+	s.failures = make(map[string]error)
+	s.runner = NewMockRunner(gomock.NewController(s.T()))
+	s.runner.EXPECT().GetRunningSessions(gomock.Any()).Return(TmuxSessions{
+		{Name: "project-1"},
+		{Name: "project-2"},
+	}, nil).AnyTimes()
+	s.runner.EXPECT().Run(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, p Project) error {
+			s.mutex.Lock()
+			defer s.mutex.Unlock()
+			s.applied = append(s.applied, p.Name)
+			err := s.failures[p.Name]
+			delete(s.failures, p.Name)
+			return err
+		}).AnyTimes()
+	s.runner.EXPECT().DisplayMessage(gomock.Any(), gomock.Any()).AnyTimes()
+}
+
+func (s *WatchConfigurationTestSuite) Eventually(actual interface{}, extra ...interface{}) AsyncAssertion {
+	return s.gomega.Eventually(actual, extra...).WithTimeout(5 * time.Second)
+}
+
+func (s *WatchConfigurationTestSuite) TearDownTest() {
+	if s.cancel != nil {
+		s.cancel()
+		s.Expect(<-s.done).To(Succeed())
+	}
+}
+
+func (s *WatchConfigurationTestSuite) watch() {
+	var ctx context.Context
+	ctx, s.cancel = context.WithCancel(context.Background())
+	s.done = make(chan error)
+	osys := configDirOS{env: map[string]string{"XDG_CONFIG_HOME": filepath.Dir(s.configDir)}}
+	go func() { s.done <- WatchConfiguration(ctx, osys, s.runner, "", time.Minute) }()
+}
+
+func (s *WatchConfigurationTestSuite) writeFile(name string, content string) {
+	file := filepath.Join(s.configDir, name)
+	s.Expect(os.MkdirAll(filepath.Dir(file), 0700)).To(Succeed())
+	s.Expect(os.WriteFile(file, []byte(content), 0600)).To(Succeed())
+}
+
+func (s *WatchConfigurationTestSuite) appliedProjects() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return slices.Clone(s.applied)
+}
+
+func (s *WatchConfigurationTestSuite) TestReapplyChangedProjects() {
+	s.writeFile("projects.yaml", applyConfig)
+	s.watch()
+	s.Eventually(s.appliedProjects).Should(HaveExactElements("project-1", "project-2"))
+
+	s.writeFile("projects.yaml", strings.Replace(applyConfig, "Editor", "Code", 1))
+	s.Eventually(s.appliedProjects).Should(
+		HaveExactElements("project-1", "project-2", "project-1"))
+}
+
+func (s *WatchConfigurationTestSuite) TestRetryFailedProjects() {
+	s.failures["project-1"] = errors.New("Failure")
+	s.writeFile("projects.yaml", applyConfig)
+	s.watch()
+	s.Eventually(s.appliedProjects).Should(HaveExactElements("project-1", "project-2"))
+
+	s.writeFile("projects.yaml", strings.Replace(applyConfig, "project-3", "project-4", 1))
+	s.Eventually(s.appliedProjects).Should(
+		HaveExactElements("project-1", "project-2", "project-1", "project-2"))
+}
+
+func (s *WatchConfigurationTestSuite) TestWatchDropInDirCreatedLater() {
+	s.writeFile("projects.yaml", "projects:\n  - name: project-1\n")
+	s.watch()
+	s.Eventually(s.appliedProjects).Should(HaveExactElements("project-1"))
+
+	s.writeFile("projects.d/extra.yaml", "projects:\n  - name: project-2\n")
+	s.Eventually(s.appliedProjects).Should(HaveExactElements("project-1", "project-2"))
+
+	s.writeFile("projects.d/extra.yaml", "projects:\n  - name: project-2\n    windows:\n      - name: Code\n")
+	s.Eventually(s.appliedProjects).Should(
+		HaveExactElements("project-1", "project-2", "project-2"))
+}
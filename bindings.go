@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// bindingsOption is the session option recording the keys bound for the
+// session, allowing keys removed from the configuration to be unbound.
+const bindingsOption = "@muxify_bindings"
+
+// bindingCommand returns the tmux command run by a key binding. As tmux key
+// bindings are global, all sessions share the binding, which calls back into
+// muxify to run the command configured for the session the key was pressed in.
+func (s TmuxServer) bindingCommand(key string) string {
+	return fmt.Sprintf("%s __key %s #{q:session_name}", shellQuote(s.executable()), shellQuote(key))
+}
+
+// savedBindingOption returns the global user option saving the binding a key
+// had before muxify bound it. Key names are hex encoded, as they can contain
+// characters not valid in option names.
+func savedBindingOption(key string) string {
+	return "@muxify_saved_binding_" + hex.EncodeToString([]byte(key))
+}
+
+// reconcileBindings binds the keys configured for the project, and unbinds
+// keys no longer configured, unless bound for another session. Keys bound by
+// the user before are restored to their previous binding.
+func (p Project) reconcileBindings(ctx context.Context, server TmuxServer, session TmuxSession) error {
+	previous, err := session.showOption(ctx, sessionScope, bindingsOption)
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(p.Bindings))
+	for key := range p.Bindings {
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 && previous == "" {
+		return nil
+	}
+	slices.Sort(keys)
+	sessions, err := server.query(
+		ctx,
+		tmuxFormat{field("session_id"), field(bindingsOption)},
+		"list-sessions",
+	)
+	if err != nil {
+		return err
+	}
+	var inUse []string
+	for _, fields := range sessions {
+		if fields[0] != session.Id {
+			inUse = append(inUse, strings.Fields(fields[1])...)
+		}
+	}
+	bound := strings.Fields(previous)
+	for _, key := range bound {
+		if !slices.Contains(keys, key) && !slices.Contains(inUse, key) {
+			if err = server.restoreBinding(ctx, key); err != nil {
+				return err
+			}
+		}
+	}
+	for _, key := range keys {
+		if !slices.Contains(bound, key) && !slices.Contains(inUse, key) {
+			if err = server.saveBinding(ctx, key); err != nil {
+				return err
+			}
+		}
+		err = server.Command(
+			ctx,
+			"bind-key", "-T", "prefix", key, "run-shell", server.bindingCommand(key),
+		).Run()
+		if err != nil {
+			return err
+		}
+	}
+	if len(keys) == 0 {
+		return session.unsetOption(ctx, sessionScope, bindingsOption)
+	}
+	return session.setOption(ctx, sessionScope, bindingsOption, strings.Join(keys, " "))
+}
+
+// savedBinding returns the bind-key command saved for the key, or "" if the
+// key wasn't bound before muxify bound it.
+func (s TmuxServer) savedBinding(ctx context.Context, key string) (string, error) {
+	output, err := s.Command(ctx, "show-options", "-g", "-q", "-v", savedBindingOption(key)).Output()
+	return sanitizeOutput(output), err
+}
+
+// saveBinding saves the binding of the key in the prefix table, if any, to be
+// restored when muxify no longer binds the key. A binding saved before is kept.
+func (s TmuxServer) saveBinding(ctx context.Context, key string) error {
+	option := savedBindingOption(key)
+	saved, err := s.savedBinding(ctx, key)
+	if err != nil || saved != "" {
+		return err
+	}
+	binding, err := s.Command(ctx, "list-keys", "-T", "prefix", key).Output()
+	if err != nil {
+		if strings.Contains(tmuxStderr(err), "unknown key") {
+			return nil
+		}
+		return err
+	}
+	return s.Command(ctx, "set-option", "-g", option, sanitizeOutput(binding)).Run()
+}
+
+// restoreBinding restores the binding saved for the key, or unbinds the key if
+// it wasn't bound before muxify bound it.
+func (s TmuxServer) restoreBinding(ctx context.Context, key string) error {
+	saved, err := s.savedBinding(ctx, key)
+	if err != nil {
+		return err
+	}
+	if saved == "" {
+		return s.Command(ctx, "unbind-key", "-T", "prefix", key).Run()
+	}
+	// if-shell parses the saved bind-key command, without expanding the formats
+	// in it, as run-shell -C would.
+	if err = s.Command(ctx, "if-shell", "-F", "1", saved).Run(); err != nil {
+		return err
+	}
+	return s.Command(ctx, "set-option", "-g", "-u", savedBindingOption(key)).Run()
+}
+
+// RunSavedBinding runs the command the key was bound to before muxify bound
+// it, for sessions not binding the key themselves. It returns false if the key
+// wasn't bound before.
+func (s TmuxServer) RunSavedBinding(ctx context.Context, key string) (bool, error) {
+	saved, err := s.savedBinding(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	command, ok := boundCommand(saved)
+	if !ok {
+		return false, nil
+	}
+	return true, s.Command(ctx, "if-shell", "-F", "1", command).Run()
+}
+
+// boundCommand returns the command of a binding, as written by list-keys, e.g.,
+// `display-message hi` for `bind-key -r -T prefix F5 display-message hi`.
+func boundCommand(binding string) (string, bool) {
+	rest := binding
+	next := func() string {
+		token, after, _ := strings.Cut(strings.TrimLeft(rest, " "), " ")
+		rest = strings.TrimLeft(after, " ")
+		return token
+	}
+	if next() != "bind-key" {
+		return "", false
+	}
+	token := next()
+	if token == "-r" {
+		token = next()
+	}
+	if token != "-T" {
+		return "", false
+	}
+	next() // the key table
+	next() // the key
+	return rest, rest != ""
+}
+
+// parseBinding returns the arguments for running the command of a key binding
+// in a session, e.g., `restart server` becomes `restart <session> server`.
+func parseBinding(binding string, sessionName string) ([]string, error) {
+	command, rest, _ := strings.Cut(strings.TrimSpace(binding), " ")
+	taskId, text, _ := strings.Cut(strings.TrimSpace(rest), " ")
+	if !slices.Contains(taskCommands, command) || taskId == "" {
+		return nil, fmt.Errorf("Invalid binding %q. Valid commands are %v followed by a task",
+			binding, taskCommands)
+	}
+	args := []string{command, sessionName, taskId}
+	if text = strings.TrimSpace(text); text != "" {
+		args = append(args, text)
+	} else if command == "send" {
+		return nil, fmt.Errorf("Invalid binding %q. send requires a command to send", binding)
+	}
+	return args, nil
+}
+
+// validateBindings verifies the bindings of all projects
+func (c MuxifyConfiguration) validateBindings() error {
+	for _, p := range c.Projects {
+		for key, binding := range p.Bindings {
+			if _, err := parseBinding(binding, p.Name); err != nil {
+				return fmt.Errorf("Project %q: bindings.%s: %w", p.Name, key, err)
+			}
+		}
+	}
+	return nil
+}
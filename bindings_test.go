@@ -0,0 +1,83 @@
+package main_test
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+
+	. "github.com/stroiman/muxify"
+)
+
+var bindingsConfiguration = `projects:
+  - name: web
+    bindings:
+      F5: restart server
+      F6: send server  make  build
+    tasks:
+      server:
+  - name: me@work
+    bindings:
+      F5: restart server
+    tasks:
+      server:
+`
+
+func bindingsCLI(t *testing.T) (CLI, *MockRunner) {
+	fakeOs := FakeOS{
+		files: fstest.MapFS{"/users/foo/.config/muxify/projects.yaml": &fstest.MapFile{
+			Data: []byte(bindingsConfiguration),
+		}},
+		env: map[string]string{"HOME": "/users/foo"},
+	}
+	mock := NewMockRunner(gomock.NewController(t))
+	return CLI{Runner: mock, OS: fakeOs}, mock
+}
+
+func TestBindingRestartsTask(t *testing.T) {
+	cli, mock := bindingsCLI(t)
+	mock.EXPECT().RestartTask(gomock.Any(), projectNamed("web"), "server")
+	assert.NoError(t, cli.Run([]string{"muxify", "__key", "F5", "web"}))
+}
+
+func TestBindingSendsToTaskInWorktreeSession(t *testing.T) {
+	cli, mock := bindingsCLI(t)
+	mock.EXPECT().SendToTask(gomock.Any(), projectNamed("web@feature"), "server", "make  build")
+	assert.NoError(t, cli.Run([]string{"muxify", "__key", "F6", "web@feature"}))
+}
+
+func TestBindingInProjectNamedLikeWorktreeSession(t *testing.T) {
+	cli, mock := bindingsCLI(t)
+	mock.EXPECT().RestartTask(gomock.Any(), projectNamed("me@work"), "server")
+	assert.NoError(t, cli.Run([]string{"muxify", "__key", "F5", "me@work"}))
+}
+
+func TestUnknownBindingIsDisplayed(t *testing.T) {
+	cli, mock := bindingsCLI(t)
+	mock.EXPECT().RunSavedBinding(gomock.Any(), "F7").Return(false, nil)
+	mock.EXPECT().DisplayMessage(gomock.Any(), `No binding for F7 in session "web"`)
+	assert.Error(t, cli.Run([]string{"muxify", "__key", "F7", "web"}))
+}
+
+func TestBindingRunsSavedBindingInOtherSessions(t *testing.T) {
+	cli, mock := bindingsCLI(t)
+	mock.EXPECT().RunSavedBinding(gomock.Any(), "F5").Return(true, nil)
+	assert.NoError(t, cli.Run([]string{"muxify", "__key", "F5", "scratch"}))
+}
+
+func TestSendCommand(t *testing.T) {
+	cli, mock := bindingsCLI(t)
+	mock.EXPECT().SendToTask(gomock.Any(), projectNamed("web"), "server", "go test ./...")
+	assert.NoError(t, cli.Run([]string{"muxify", "send", "web", "server", "go test ./..."}))
+}
+
+func TestInvalidBinding(t *testing.T) {
+	_, err := Decode(strings.NewReader(`projects:
+  - name: web
+    bindings:
+      F5: reload server
+`))
+	assert.ErrorContains(t, err, `Project "web": bindings.F5: Invalid binding "reload server"`)
+}
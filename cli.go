@@ -1,37 +1,187 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"io/fs"
 	"log/slog"
 	"os"
+	"os/signal"
+	"slices"
 	"strings"
+	"syscall"
+	"time"
 )
 
 type DefaultRunner struct {
 }
 
-func (r DefaultRunner) Run(p Project) error {
-	_, err := p.EnsureStarted(TmuxServer{})
+func (r DefaultRunner) Run(ctx context.Context, p Project) error {
+	_, err := p.EnsureStarted(ctx, TmuxServer{})
 	return err
 }
 
+func (r DefaultRunner) GetRunningSessions(ctx context.Context) (TmuxSessions, error) {
+	return TmuxServer{}.GetRunningSessions(ctx)
+}
+
+func (r DefaultRunner) DisplayMessage(ctx context.Context, message string) error {
+	return TmuxServer{}.DisplayMessage(ctx, message)
+}
+
+func (r DefaultRunner) RunSavedBinding(ctx context.Context, key string) (bool, error) {
+	return TmuxServer{}.RunSavedBinding(ctx, key)
+}
+
+// Attach runs an interactive tmux client, which isn't stopped by cancelling the
+// context, e.g., by the timeout for starting sessions.
+func (r DefaultRunner) Attach(ctx context.Context, sessionName string) error {
+	return TmuxServer{}.Attach(context.WithoutCancel(ctx), sessionName)
+}
+
+func (r DefaultRunner) StopSession(ctx context.Context, sessionName string) error {
+	return TmuxServer{}.KillSessionByName(ctx, sessionName)
+}
+
+func (r DefaultRunner) CaptureSession(ctx context.Context, p Project) (SavedSession, error) {
+	return CaptureSession(ctx, TmuxServer{}, p)
+}
+
+func (r DefaultRunner) SendToTask(ctx context.Context, p Project, taskId TaskId, command string) error {
+	return p.SendToTask(ctx, TmuxServer{}, taskId, command)
+}
+
+func (r DefaultRunner) RestartTask(ctx context.Context, p Project, taskId TaskId) error {
+	return p.RestartTask(ctx, TmuxServer{}, taskId)
+}
+
+func (r DefaultRunner) GetProjectStatus(ctx context.Context, p Project) (ProjectStatus, error) {
+	return TmuxServer{}.GetProjectStatus(ctx, p)
+}
+
+func (r DefaultRunner) HandlePaneDied(ctx context.Context, socketPath string, paneId string) error {
+	return TmuxServer{SocketPath: socketPath}.HandlePaneDied(ctx, paneId)
+}
+
 type Runner interface {
-	Run(p Project) error
+	Run(ctx context.Context, p Project) error
+	GetRunningSessions(ctx context.Context) (TmuxSessions, error)
+	DisplayMessage(ctx context.Context, message string) error
+	RunSavedBinding(ctx context.Context, key string) (bool, error)
+	Attach(ctx context.Context, sessionName string) error
+	StopSession(ctx context.Context, sessionName string) error
+	CaptureSession(ctx context.Context, p Project) (SavedSession, error)
+	SendToTask(ctx context.Context, p Project, taskId TaskId, command string) error
+	RestartTask(ctx context.Context, p Project, taskId TaskId) error
+	HandlePaneDied(ctx context.Context, socketPath string, paneId string) error
+	GetProjectStatus(ctx context.Context, p Project) (ProjectStatus, error)
 }
 
 type CLI struct {
 	Runner
 	OS
+	Stdout io.Writer
+}
+
+func (cli CLI) stdout() io.Writer {
+	if cli.Stdout == nil {
+		return os.Stdout
+	}
+	return cli.Stdout
+}
+
+// parseInterspersed parses flags appearing both before and after positional
+// arguments, e.g., `muxify project --worktree feature-x`, returning the
+// positional arguments, and whether the first follows a `--` terminator.
+func parseInterspersed(flagSet *flag.FlagSet, args []string) (positional []string, terminated bool, err error) {
+	for {
+		if err = flagSet.Parse(args); err != nil {
+			return nil, false, err
+		}
+		if flagSet.NArg() == 0 {
+			return positional, terminated, nil
+		}
+		if parsed := len(args) - flagSet.NArg(); len(positional) == 0 && parsed > 0 {
+			terminated = args[parsed-1] == "--"
+		}
+		positional = append(positional, flagSet.Arg(0))
+		args = flagSet.Args()[1:]
+	}
+}
+
+// ErrInterrupted is the cause of cancelling when muxify is interrupted, e.g., by
+// Ctrl-C.
+var ErrInterrupted = errors.New("Interrupted")
+
+// defaultTimeout is the default maximum time for starting or updating sessions,
+// after which muxify stops, e.g., if the tmux server doesn't respond.
+const defaultTimeout = time.Minute
+
+// interruptContext returns a context cancelled when muxify is interrupted
+func interruptContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-signals:
+			cancel(ErrInterrupted)
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() {
+		signal.Stop(signals)
+		cancel(nil)
+	}
+}
+
+// withTimeout limits the context to the timeout, unless it is zero
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeoutCause(ctx, timeout, fmt.Errorf("Timed out after %s", timeout))
 }
 
 func (cli CLI) Run(args []string) error {
+	ctx, stop := interruptContext()
+	defer stop()
 	var verbose bool
+	var worktree string
+	var profile string
+	var watch bool
+	var focus string
+	var jsonOutput bool
+	var timeout time.Duration
 	flagSet := flag.NewFlagSet("muxify", flag.ExitOnError)
 	flagSet.BoolVar(&verbose, "v", false, "Verbose output logging")
-	if err := flagSet.Parse(args[1:]); err != nil {
+	flagSet.StringVar(&worktree, "worktree", "",
+		"Start the project in a separate session for a git worktree of the branch")
+	flagSet.StringVar(&profile, "profile", "",
+		"Profile used for when conditions. Defaults to $MUXIFY_PROFILE")
+	flagSet.BoolVar(&watch, "watch", false,
+		"With apply, reapply the configuration when configuration files change")
+	flagSet.StringVar(&focus, "focus", "",
+		"Select the pane of the task after starting, overriding focus in the configuration")
+	flagSet.BoolVar(&jsonOutput, "json", false, "With status, write the status as JSON")
+	flagSet.DurationVar(&timeout, "timeout", defaultTimeout,
+		"Maximum time for starting or updating sessions, 0 for no limit")
+	if len(args) > 1 && args[1] == "__complete" {
+		return cli.complete(args[2:])
+	}
+	if len(args) > 3 && args[1] == "__key" {
+		return cli.runBinding(ctx, args[2], args[3])
+	}
+	if len(args) > 3 && args[1] == "__pane-died" {
+		ctx, cancel := withTimeout(ctx, defaultTimeout)
+		defer cancel()
+		return cli.Runner.HandlePaneDied(ctx, args[2], args[3])
+	}
+	positional, terminated, err := parseInterspersed(flagSet, args[1:])
+	if err != nil {
 		return err
 	}
 	if verbose {
@@ -39,21 +189,296 @@ func (cli CLI) Run(args []string) error {
 	} else {
 		slog.SetLogLoggerLevel(slog.LevelWarn)
 	}
+	arg := func(i int) string {
+		if i < len(positional) {
+			return positional[i]
+		}
+		return ""
+	}
+	command := arg(0)
+	if terminated {
+		// `muxify -- <project name>` starts a project named like a command
+		command = ""
+	}
+	if command == "completion" {
+		return WriteCompletionScript(cli.stdout(), arg(1))
+	}
+	if command == "schema" {
+		return WriteJSONSchema(cli.stdout())
+	}
+	if profile == "" {
+		profile, _ = cli.LookupEnv("MUXIFY_PROFILE")
+	}
+	if command == "apply" && watch {
+		return WatchConfiguration(ctx, cli, cli.Runner, profile, timeout)
+	}
+	ctx, cancel := withTimeout(ctx, timeout)
+	defer cancel()
 	configuration, err := ReadConfiguration(cli)
 	if err != nil {
 		return err
 	}
-	projectName := flagSet.Arg(0)
-	if project, ok := configuration.GetProject(projectName); ok {
-		return cli.Runner.Run(project)
+	if _, ok := configuration.GetProject(command); ok && slices.Contains(subCommands, command) {
+		slog.Warn("Running the command, not the project of the same name. "+
+			"Start the project with `muxify -- <project name>`", "project", command)
+	}
+	if command == "apply" {
+		return Apply(ctx, cli.Runner, configuration, nil, profile)
+	}
+	if command == "up" || command == "stop" {
+		projects, ok := configuration.GetGroupProjects(arg(1))
+		if !ok {
+			return fmt.Errorf("No group or project named %q", arg(1))
+		}
+		for i := range projects {
+			projects[i].Profile = profile
+			if command == "up" {
+				if projects[i], err = projects[i].Resolve(); err != nil {
+					return err
+				}
+			}
+			if projects[i].Saved, err = cli.readSavedSession(projects[i].Name); err != nil {
+				return err
+			}
+		}
+		if command == "up" {
+			return Up(ctx, cli.Runner, projects)
+		}
+		return Stop(ctx, cli.Runner, projects)
+	}
+	if command == "status" {
+		return cli.status(ctx, configuration, positional[1:], profile, jsonOutput)
+	}
+	if command == "list" {
+		sessions, err := cli.Runner.GetRunningSessions(ctx)
+		if err != nil {
+			return err
+		}
+		return WriteProjectList(cli.stdout(), configuration, sessions)
+	}
+	if command == "send" || command == "restart" {
+		project, err := configuration.getSessionProject(arg(1))
+		if err != nil {
+			return err
+		}
+		if command == "restart" {
+			return cli.Runner.RestartTask(ctx, project, arg(2))
+		}
+		if len(positional) < 4 {
+			return errors.New("Usage: muxify send <project> <task> <command>")
+		}
+		return cli.Runner.SendToTask(ctx, project, arg(2), strings.Join(positional[3:], " "))
+	}
+	if command == "save" {
+		project, err := configuration.getResolvedProject(arg(1))
+		if err != nil {
+			return err
+		}
+		return cli.save(ctx, project)
+	}
+	if command == "plan" {
+		project, err := configuration.getResolvedProject(arg(1))
+		if err != nil {
+			return err
+		}
+		project.Profile = profile
+		return WritePlan(cli.stdout(), project)
+	}
+	project, err := configuration.getProjectOrFail(arg(0))
+	if err != nil {
+		return err
+	}
+	project.Profile = profile
+	project.FocusTask = focus
+	if worktree != "" {
+		if project, err = project.ForWorktree(worktree); err != nil {
+			return err
+		}
+	}
+	if project, err = project.Resolve(); err != nil {
+		return err
+	}
+	if project.Saved, err = cli.readSavedSession(project.Name); err != nil {
+		return err
+	}
+	return cli.Runner.Run(ctx, project)
+}
+
+// getSessionProject returns the resolved project running in the session, which
+// can be a worktree session of the project.
+func (c MuxifyConfiguration) getSessionProject(sessionName string) (Project, error) {
+	projectName, _, _ := c.sessionProjectName(sessionName)
+	project, err := c.getResolvedProject(projectName)
+	project.Name = sessionName
+	return project, err
+}
+
+// runBinding runs the command bound to the key for the project of the session.
+// It is run by tmux, so errors are displayed in tmux.
+func (cli CLI) runBinding(ctx context.Context, key string, sessionName string) error {
+	err := cli.runBindingCommand(ctx, key, sessionName)
+	if err != nil {
+		ctx, cancel := withTimeout(ctx, defaultTimeout)
+		defer cancel()
+		cli.Runner.DisplayMessage(ctx, err.Error())
+	}
+	return err
+}
+
+func (cli CLI) runBindingCommand(ctx context.Context, key string, sessionName string) error {
+	configuration, err := ReadConfiguration(cli)
+	if err != nil {
+		return err
+	}
+	project, err := configuration.getSessionProject(sessionName)
+	if err != nil && !errors.Is(err, errProjectNotFound) {
+		return err
+	}
+	binding, ok := project.Bindings[key]
+	if !ok {
+		// Key bindings are global, so the key is also pressed in sessions of
+		// other projects, which get the binding the key had before.
+		if found, err := cli.Runner.RunSavedBinding(ctx, key); found || err != nil {
+			return err
+		}
+		return fmt.Errorf("No binding for %s in session %q", key, sessionName)
+	}
+	args, err := parseBinding(binding, sessionName)
+	if err != nil {
+		return err
+	}
+	return cli.Run(append([]string{"muxify"}, args...))
+}
+
+// status writes the status of the named projects, or all configured projects,
+// returning ErrDrift if a running session differs from the configuration.
+func (cli CLI) status(
+	ctx context.Context,
+	configuration MuxifyConfiguration,
+	projectNames []string,
+	profile string,
+	jsonOutput bool,
+) error {
+	if len(projectNames) == 0 {
+		var err error
+		if projectNames, err = cli.statusSessionNames(ctx, configuration); err != nil {
+			return err
+		}
+	}
+	statuses := make([]ProjectStatus, len(projectNames))
+	drift := false
+	for i, name := range projectNames {
+		project, err := configuration.getSessionProject(name)
+		if err != nil {
+			return err
+		}
+		project.Profile = profile
+		if statuses[i], err = cli.Runner.GetProjectStatus(ctx, project); err != nil {
+			return err
+		}
+		drift = drift || statuses[i].HasDrift()
+	}
+	var err error
+	if jsonOutput {
+		err = WriteStatusJSON(cli.stdout(), statuses)
+	} else {
+		err = WriteStatus(cli.stdout(), statuses)
+	}
+	if err == nil && drift {
+		err = ErrDrift
+	}
+	return err
+}
+
+// statusSessionNames returns the names of the configured projects, each
+// followed by the running sessions of the project in a git worktree.
+func (cli CLI) statusSessionNames(
+	ctx context.Context,
+	configuration MuxifyConfiguration,
+) ([]string, error) {
+	sessions, err := cli.Runner.GetRunningSessions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, name := range configuration.ProjectNames() {
+		names = append(names, name)
+		for _, session := range sessions {
+			if parent, _, ok := configuration.sessionProjectName(session.Name); ok && parent == name {
+				names = append(names, session.Name)
+			}
+		}
+	}
+	return names, nil
+}
+
+// save captures the state of the project's session, and writes it to the state
+// dir, e.g., ~/.local/state/muxify/<project name>
+func (cli CLI) save(ctx context.Context, project Project) error {
+	dir, err := getStateDirPath(cli)
+	if err != nil {
+		return err
+	}
+	session, err := cli.Runner.CaptureSession(ctx, project)
+	if err != nil {
+		return err
+	}
+	return WriteSavedSession(dir, project.Name, session)
+}
+
+func (cli CLI) readSavedSession(projectName string) (*SavedSession, error) {
+	dir, err := getStateDirPath(cli)
+	if err != nil {
+		return nil, err
+	}
+	return ReadSavedSession(cli.Dir(dir), projectName)
+}
+
+var errProjectNotFound = errors.New("The project was not found")
+
+// getProjectOrFail returns the named project, or an error listing the valid
+// project names.
+func (c MuxifyConfiguration) getProjectOrFail(projectName string) (Project, error) {
+	if project, ok := c.GetProject(projectName); ok {
+		return project, nil
 	} else {
 		var b strings.Builder
-		b.WriteString("The project was not found. Valid project names are:\n")
-		for _, p := range configuration.Projects {
+		b.WriteString(". Valid project names are:\n")
+		for _, p := range c.Projects {
 			b.WriteString(fmt.Sprintf(" - %s\n", p.Name))
 		}
-		return errors.New(b.String())
+		return project, fmt.Errorf("%w%s", errProjectNotFound, b.String())
+	}
+}
+
+// getResolvedProject returns the named project with variables interpolated
+func (c MuxifyConfiguration) getResolvedProject(projectName string) (Project, error) {
+	project, err := c.getProjectOrFail(projectName)
+	if err != nil {
+		return project, err
+	}
+	return project.Resolve()
+}
+
+// complete writes completion candidates, one per line. Flags are ignored, so
+// `muxify -v <TAB>` completes the same as `muxify <TAB>`.
+func (cli CLI) complete(args []string) error {
+	configuration, err := ReadConfiguration(cli)
+	if err != nil {
+		return err
+	}
+	words := make([]string, 0, len(args))
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			words = append(words, arg)
+		}
+	}
+	for _, candidate := range Complete(configuration, words) {
+		if _, err := fmt.Fprintln(cli.stdout(), candidate); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
 type RealOS struct{}
@@ -67,10 +492,11 @@ func (o RealOS) LookupEnv(name string) (string, bool) {
 }
 
 func main() {
-	err := CLI{DefaultRunner{}, RealOS{}}.Run(os.Args)
+	err := CLI{DefaultRunner{}, RealOS{}, os.Stdout}.Run(os.Args)
 	if err == nil {
 		os.Exit(0)
 	} else {
 		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		os.Exit(1)
 	}
 }
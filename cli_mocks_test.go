@@ -10,6 +10,7 @@
 package main_test
 
 import (
+	context "context"
 	reflect "reflect"
 
 	main "github.com/stroiman/muxify"
@@ -39,16 +40,160 @@ func (m *MockRunner) EXPECT() *MockRunnerMockRecorder {
 	return m.recorder
 }
 
+// Attach mocks base method.
+func (m *MockRunner) Attach(ctx context.Context, sessionName string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Attach", ctx, sessionName)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Attach indicates an expected call of Attach.
+func (mr *MockRunnerMockRecorder) Attach(ctx, sessionName any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Attach", reflect.TypeOf((*MockRunner)(nil).Attach), ctx, sessionName)
+}
+
+// CaptureSession mocks base method.
+func (m *MockRunner) CaptureSession(ctx context.Context, p main.Project) (main.SavedSession, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CaptureSession", ctx, p)
+	ret0, _ := ret[0].(main.SavedSession)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CaptureSession indicates an expected call of CaptureSession.
+func (mr *MockRunnerMockRecorder) CaptureSession(ctx, p any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CaptureSession", reflect.TypeOf((*MockRunner)(nil).CaptureSession), ctx, p)
+}
+
+// DisplayMessage mocks base method.
+func (m *MockRunner) DisplayMessage(ctx context.Context, message string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DisplayMessage", ctx, message)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DisplayMessage indicates an expected call of DisplayMessage.
+func (mr *MockRunnerMockRecorder) DisplayMessage(ctx, message any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DisplayMessage", reflect.TypeOf((*MockRunner)(nil).DisplayMessage), ctx, message)
+}
+
+// GetProjectStatus mocks base method.
+func (m *MockRunner) GetProjectStatus(ctx context.Context, p main.Project) (main.ProjectStatus, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetProjectStatus", ctx, p)
+	ret0, _ := ret[0].(main.ProjectStatus)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetProjectStatus indicates an expected call of GetProjectStatus.
+func (mr *MockRunnerMockRecorder) GetProjectStatus(ctx, p any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetProjectStatus", reflect.TypeOf((*MockRunner)(nil).GetProjectStatus), ctx, p)
+}
+
+// GetRunningSessions mocks base method.
+func (m *MockRunner) GetRunningSessions(ctx context.Context) (main.TmuxSessions, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRunningSessions", ctx)
+	ret0, _ := ret[0].(main.TmuxSessions)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRunningSessions indicates an expected call of GetRunningSessions.
+func (mr *MockRunnerMockRecorder) GetRunningSessions(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRunningSessions", reflect.TypeOf((*MockRunner)(nil).GetRunningSessions), ctx)
+}
+
+// HandlePaneDied mocks base method.
+func (m *MockRunner) HandlePaneDied(ctx context.Context, socketPath, paneId string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HandlePaneDied", ctx, socketPath, paneId)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// HandlePaneDied indicates an expected call of HandlePaneDied.
+func (mr *MockRunnerMockRecorder) HandlePaneDied(ctx, socketPath, paneId any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HandlePaneDied", reflect.TypeOf((*MockRunner)(nil).HandlePaneDied), ctx, socketPath, paneId)
+}
+
+// RestartTask mocks base method.
+func (m *MockRunner) RestartTask(ctx context.Context, p main.Project, taskId main.TaskId) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestartTask", ctx, p, taskId)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RestartTask indicates an expected call of RestartTask.
+func (mr *MockRunnerMockRecorder) RestartTask(ctx, p, taskId any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestartTask", reflect.TypeOf((*MockRunner)(nil).RestartTask), ctx, p, taskId)
+}
+
 // Run mocks base method.
-func (m *MockRunner) Run(p main.Project) error {
+func (m *MockRunner) Run(ctx context.Context, p main.Project) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Run", p)
+	ret := m.ctrl.Call(m, "Run", ctx, p)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Run indicates an expected call of Run.
-func (mr *MockRunnerMockRecorder) Run(p any) *gomock.Call {
+func (mr *MockRunnerMockRecorder) Run(ctx, p any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Run", reflect.TypeOf((*MockRunner)(nil).Run), ctx, p)
+}
+
+// RunSavedBinding mocks base method.
+func (m *MockRunner) RunSavedBinding(ctx context.Context, key string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RunSavedBinding", ctx, key)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RunSavedBinding indicates an expected call of RunSavedBinding.
+func (mr *MockRunnerMockRecorder) RunSavedBinding(ctx, key any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RunSavedBinding", reflect.TypeOf((*MockRunner)(nil).RunSavedBinding), ctx, key)
+}
+
+// SendToTask mocks base method.
+func (m *MockRunner) SendToTask(ctx context.Context, p main.Project, taskId main.TaskId, command string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendToTask", ctx, p, taskId, command)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SendToTask indicates an expected call of SendToTask.
+func (mr *MockRunnerMockRecorder) SendToTask(ctx, p, taskId, command any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendToTask", reflect.TypeOf((*MockRunner)(nil).SendToTask), ctx, p, taskId, command)
+}
+
+// StopSession mocks base method.
+func (m *MockRunner) StopSession(ctx context.Context, sessionName string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StopSession", ctx, sessionName)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// StopSession indicates an expected call of StopSession.
+func (mr *MockRunnerMockRecorder) StopSession(ctx, sessionName any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Run", reflect.TypeOf((*MockRunner)(nil).Run), p)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StopSession", reflect.TypeOf((*MockRunner)(nil).StopSession), ctx, sessionName)
 }
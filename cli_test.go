@@ -2,8 +2,16 @@
 package main_test
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path"
+	"syscall"
 	"testing"
 	"testing/fstest"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	. "github.com/stroiman/muxify"
@@ -19,10 +27,10 @@ func TestCli(t *testing.T) {
 	}
 	controller := gomock.NewController(t)
 	mock := NewMockRunner(controller)
-	cli := CLI{mock, fakeOs}
-	call := mock.EXPECT().Run(gomock.Any())
+	cli := CLI{Runner: mock, OS: fakeOs}
+	call := mock.EXPECT().Run(gomock.Any(), gomock.Any())
 	var actualProject Project
-	call.Do(func(project Project) {
+	call.Do(func(_ context.Context, project Project) {
 		actualProject = project
 	})
 	cli.Run([]string{"muxify", "Project 1"})
@@ -39,10 +47,10 @@ func TestCliWithVerbose(t *testing.T) {
 	}
 	controller := gomock.NewController(t)
 	mock := NewMockRunner(controller)
-	cli := CLI{mock, fakeOs}
-	call := mock.EXPECT().Run(gomock.Any())
+	cli := CLI{Runner: mock, OS: fakeOs}
+	call := mock.EXPECT().Run(gomock.Any(), gomock.Any())
 	var actualProject Project
-	call.Do(func(project Project) {
+	call.Do(func(_ context.Context, project Project) {
 		actualProject = project
 	})
 	cli.Run([]string{"muxify", "-v", "Project 1"})
@@ -50,7 +58,251 @@ func TestCliWithVerbose(t *testing.T) {
 	assert.Equal(t, Project{Name: "Project 1"}, actualProject)
 }
 
+func TestCliStartsProjectNamedLikeCommandAfterTerminator(t *testing.T) {
+	fakeOs := FakeOS{
+		files: fstest.MapFS{"/users/foo/.config/muxify/projects.yaml": &fstest.MapFile{
+			Data: []byte("projects:\n  - name: list"),
+		}},
+		env: map[string]string{"HOME": "/users/foo"},
+	}
+	controller := gomock.NewController(t)
+	mock := NewMockRunner(controller)
+	var stdout bytes.Buffer
+	cli := CLI{Runner: mock, OS: fakeOs, Stdout: &stdout}
+	mock.EXPECT().GetRunningSessions(gomock.Any())
+	assert.NoError(t, cli.Run([]string{"muxify", "list"}))
+	assert.Equal(t, "  list\n", stdout.String())
+
+	mock.EXPECT().Run(gomock.Any(), projectNamed("list"))
+	assert.NoError(t, cli.Run([]string{"muxify", "-v", "--", "list"}))
+	controller.Finish()
+}
+
+func TestCliCompletion(t *testing.T) {
+	fakeOs := FakeOS{
+		files: fstest.MapFS{"/users/foo/.config/muxify/projects.yaml": &fstest.MapFile{
+			Data: []byte(configuration),
+		}},
+		env: map[string]string{"HOME": "/users/foo"},
+	}
+	var stdout bytes.Buffer
+	cli := CLI{OS: fakeOs, Stdout: &stdout}
+	assert.NoError(t, cli.Run([]string{"muxify", "__complete", "-v"}))
+	assert.Equal(t, "apply\ncompletion\nlist\nplan\nrestart\nsave\nschema\nsend\nstatus\nstop\nup\nProject 1\nProject 2\n", stdout.String())
+}
+
+func TestCliCompletionScript(t *testing.T) {
+	var stdout bytes.Buffer
+	cli := CLI{OS: FakeOS{}, Stdout: &stdout}
+	assert.NoError(t, cli.Run([]string{"muxify", "completion", "bash"}))
+	assert.Contains(t, stdout.String(), "muxify __complete")
+	assert.Error(t, cli.Run([]string{"muxify", "completion", "powershell"}))
+}
+
+func TestCliProfile(t *testing.T) {
+	fakeOs := FakeOS{
+		files: fstest.MapFS{"/users/foo/.config/muxify/projects.yaml": &fstest.MapFile{
+			Data: []byte(configuration),
+		}},
+		env: map[string]string{"HOME": "/users/foo", "MUXIFY_PROFILE": "laptop"},
+	}
+	controller := gomock.NewController(t)
+	mock := NewMockRunner(controller)
+	cli := CLI{Runner: mock, OS: fakeOs}
+	var profiles []string
+	mock.EXPECT().Run(gomock.Any(), gomock.Any()).Times(2).Do(func(_ context.Context, project Project) {
+		profiles = append(profiles, project.Profile)
+	})
+	cli.Run([]string{"muxify", "Project 1"})
+	cli.Run([]string{"muxify", "--profile", "office", "Project 1"})
+	controller.Finish()
+	assert.Equal(t, []string{"laptop", "office"}, profiles)
+}
+
+func TestCliList(t *testing.T) {
+	fakeOs := FakeOS{
+		files: fstest.MapFS{"/users/foo/.config/muxify/projects.yaml": &fstest.MapFile{
+			Data: []byte(configuration),
+		}},
+		env: map[string]string{"HOME": "/users/foo"},
+	}
+	controller := gomock.NewController(t)
+	mock := NewMockRunner(controller)
+	var stdout bytes.Buffer
+	cli := CLI{Runner: mock, OS: fakeOs, Stdout: &stdout}
+	mock.EXPECT().GetRunningSessions(gomock.Any()).Return(TmuxSessions{
+		{Name: "Project 2"},
+		{Name: "Project 2@feature-x"},
+		{Name: "Other"},
+	}, nil)
+	assert.NoError(t, cli.Run([]string{"muxify", "list"}))
+	controller.Finish()
+	assert.Equal(t, "  Project 1\n* Project 2\n  * Project 2@feature-x\n", stdout.String())
+}
+
+func TestCliWorktreeFlagAfterProjectName(t *testing.T) {
+	repo := path.Join(t.TempDir(), "repo")
+	for _, args := range [][]string{
+		{"init", "-q", repo},
+		{"-C", repo, "-c", "user.name=muxify", "-c", "user.email=muxify@example.com",
+			"commit", "-q", "--allow-empty", "-m", "Initial commit"},
+	} {
+		if output, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatal(string(output))
+		}
+	}
+	fakeOs := FakeOS{
+		files: fstest.MapFS{"/users/foo/.config/muxify/projects.yaml": &fstest.MapFile{
+			Data: []byte("projects:\n  - name: Project 1\n    working_dir: " + repo + "\n"),
+		}},
+		env: map[string]string{"HOME": "/users/foo"},
+	}
+	controller := gomock.NewController(t)
+	mock := NewMockRunner(controller)
+	cli := CLI{Runner: mock, OS: fakeOs}
+	mock.EXPECT().Run(gomock.Any(), projectNamed("Project 1@feature-x"))
+	err := cli.Run([]string{"muxify", "Project 1", "--worktree", "feature-x"})
+	controller.Finish()
+	assert.NoError(t, err)
+}
+
 var configuration = `projects:
   - name: Project 1
   - name: Project 2
 `
+
+func TestCliRestoresSavedSession(t *testing.T) {
+	fakeOs := FakeOS{
+		files: fstest.MapFS{
+			"/users/foo/.config/muxify/projects.yaml": &fstest.MapFile{
+				Data: []byte(configuration),
+			},
+			"/users/foo/.local/state/muxify/Project 1/session.json": &fstest.MapFile{
+				Data: []byte(`{"windows": [{"name": "Editor", "panes": [{"task": "editor"}]}]}`),
+			},
+		},
+		env: map[string]string{"HOME": "/users/foo"},
+	}
+	controller := gomock.NewController(t)
+	mock := NewMockRunner(controller)
+	cli := CLI{Runner: mock, OS: fakeOs}
+	var actualProject Project
+	mock.EXPECT().Run(gomock.Any(), gomock.Any()).Do(func(_ context.Context, project Project) {
+		actualProject = project
+	})
+	assert.NoError(t, cli.Run([]string{"muxify", "Project 1"}))
+	controller.Finish()
+	assert.Equal(t, &SavedSession{Windows: []SavedWindow{{
+		Name:  "Editor",
+		Panes: []SavedPane{{Task: "editor"}},
+	}}}, actualProject.Saved)
+}
+
+func TestCliSave(t *testing.T) {
+	stateDir := t.TempDir()
+	fakeOs := FakeOS{
+		files: fstest.MapFS{"/users/foo/.config/muxify/projects.yaml": &fstest.MapFile{
+			Data: []byte(configuration),
+		}},
+		env: map[string]string{"HOME": "/users/foo", "XDG_STATE_HOME": stateDir},
+	}
+	controller := gomock.NewController(t)
+	mock := NewMockRunner(controller)
+	cli := CLI{Runner: mock, OS: fakeOs}
+	mock.EXPECT().CaptureSession(gomock.Any(), projectNamed("Project 1")).Return(SavedSession{
+		Windows: []SavedWindow{{Name: "Editor", Panes: []SavedPane{
+			{Task: "editor", Path: "/src", History: "$ make\nok"},
+		}}},
+	}, nil)
+	assert.NoError(t, cli.Run([]string{"muxify", "save", "Project 1"}))
+	controller.Finish()
+
+	saved, err := ReadSavedSession(os.DirFS(path.Join(stateDir, "muxify")), "Project 1")
+	assert.NoError(t, err)
+	pane := saved.Windows[0].Panes[0]
+	assert.Equal(t, "/src", pane.Path)
+	history, err := os.ReadFile(pane.HistoryFile)
+	assert.NoError(t, err)
+	assert.Equal(t, "$ make\nok\n", string(history))
+}
+
+func TestSavedSessionStaysInStateDir(t *testing.T) {
+	stateDir := path.Join(t.TempDir(), "state")
+	other := path.Join(path.Dir(stateDir), "other.txt")
+	assert.NoError(t, os.WriteFile(other, nil, 0600))
+	session := SavedSession{Windows: []SavedWindow{{Name: "Editor"}}}
+	for _, name := range []string{"..", ".", "a/../..", ""} {
+		assert.NoError(t, WriteSavedSession(stateDir, name, session))
+		saved, err := ReadSavedSession(os.DirFS(stateDir), name)
+		assert.NoError(t, err)
+		assert.Equal(t, &session, saved)
+	}
+	assert.FileExists(t, other)
+}
+
+func TestCliFocus(t *testing.T) {
+	fakeOs := FakeOS{
+		files: fstest.MapFS{"/users/foo/.config/muxify/projects.yaml": &fstest.MapFile{
+			Data: []byte(configuration),
+		}},
+		env: map[string]string{"HOME": "/users/foo"},
+	}
+	controller := gomock.NewController(t)
+	mock := NewMockRunner(controller)
+	cli := CLI{Runner: mock, OS: fakeOs}
+	var actualProject Project
+	mock.EXPECT().Run(gomock.Any(), gomock.Any()).Do(func(_ context.Context, project Project) {
+		actualProject = project
+	})
+	assert.NoError(t, cli.Run([]string{"muxify", "Project 1", "--focus", "editor"}))
+	controller.Finish()
+	assert.Equal(t, "editor", actualProject.FocusTask)
+}
+
+func TestCliTimeout(t *testing.T) {
+	fakeOs := FakeOS{
+		files: fstest.MapFS{"/users/foo/.config/muxify/projects.yaml": &fstest.MapFile{
+			Data: []byte(configuration),
+		}},
+		env: map[string]string{"HOME": "/users/foo"},
+	}
+	controller := gomock.NewController(t)
+	mock := NewMockRunner(controller)
+	cli := CLI{Runner: mock, OS: fakeOs}
+	var deadlines []time.Duration
+	mock.EXPECT().Run(gomock.Any(), gomock.Any()).Times(2).Do(func(ctx context.Context, _ Project) {
+		if deadline, ok := ctx.Deadline(); ok {
+			deadlines = append(deadlines, time.Until(deadline).Round(time.Second))
+		} else {
+			deadlines = append(deadlines, 0)
+		}
+	})
+	assert.NoError(t, cli.Run([]string{"muxify", "--timeout", "5s", "Project 1"}))
+	assert.NoError(t, cli.Run([]string{"muxify", "--timeout", "0", "Project 1"}))
+	controller.Finish()
+	assert.Equal(t, []time.Duration{5 * time.Second, 0}, deadlines)
+}
+
+func TestCliInterrupt(t *testing.T) {
+	fakeOs := FakeOS{
+		files: fstest.MapFS{"/users/foo/.config/muxify/projects.yaml": &fstest.MapFile{
+			Data: []byte(configuration),
+		}},
+		env: map[string]string{"HOME": "/users/foo"},
+	}
+	controller := gomock.NewController(t)
+	mock := NewMockRunner(controller)
+	cli := CLI{Runner: mock, OS: fakeOs}
+	mock.EXPECT().Run(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, _ Project) error {
+		assert.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGINT))
+		select {
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		case <-time.After(5 * time.Second):
+			return errors.New("Not interrupted")
+		}
+	})
+	err := cli.Run([]string{"muxify", "Project 1"})
+	controller.Finish()
+	assert.ErrorIs(t, err, ErrInterrupted)
+}
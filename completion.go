@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+)
+
+// Shells for which a completion script can be generated
+var completionShells = []string{"bash", "zsh", "fish"}
+
+// taskCommands are the sub commands that take a project name followed by a
+// task name.
+var taskCommands = []string{"send", "restart"}
+
+// groupCommands are the sub commands that take a group or project name
+var groupCommands = []string{"up", "stop"}
+
+// projectCommands are the sub commands that take a project name
+var projectCommands = []string{"plan", "save", "status"}
+
+// subCommands are the sub commands offered as completion candidates for the
+// first argument, in addition to the configured project names.
+var subCommands = []string{
+	"apply", "completion", "list", "plan", "restart", "save", "schema", "send", "status", "stop",
+	"up",
+}
+
+// Complete returns the completion candidates for the next argument, given the
+// arguments already typed on the command line (not including the program
+// name).
+func Complete(config MuxifyConfiguration, args []string) []string {
+	if len(args) == 0 {
+		return append(slices.Clone(subCommands), config.ProjectNames()...)
+	}
+	command := args[0]
+	switch {
+	case command == "completion" && len(args) == 1:
+		return slices.Clone(completionShells)
+	case slices.Contains(groupCommands, command) && len(args) == 1:
+		return append(config.GroupNames(), config.ProjectNames()...)
+	case slices.Contains(projectCommands, command) && len(args) == 1:
+		return config.ProjectNames()
+	case slices.Contains(taskCommands, command) && len(args) == 1:
+		return config.ProjectNames()
+	case slices.Contains(taskCommands, command) && len(args) == 2:
+		if project, ok := config.GetProject(args[1]); ok {
+			return project.TaskNames()
+		}
+	}
+	return nil
+}
+
+func (c MuxifyConfiguration) ProjectNames() []string {
+	result := make([]string, len(c.Projects))
+	for i, p := range c.Projects {
+		result[i] = p.Name
+	}
+	return result
+}
+
+func (c MuxifyConfiguration) GroupNames() []string {
+	result := make([]string, 0, len(c.Groups))
+	for name := range c.Groups {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func (p Project) TaskNames() []string {
+	result := make([]string, 0, len(p.Tasks))
+	for name := range p.Tasks {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// WriteCompletionScript writes a completion script for the shell. The scripts
+// delegate the actual work to the hidden `__complete` sub command, so the
+// candidates always reflect the current configuration.
+func WriteCompletionScript(w io.Writer, shell string) error {
+	var script string
+	switch shell {
+	case "bash":
+		script = bashCompletion
+	case "zsh":
+		script = zshCompletion
+	case "fish":
+		script = fishCompletion
+	default:
+		return fmt.Errorf("Unsupported shell: %s. Valid shells are %v", shell, completionShells)
+	}
+	_, err := io.WriteString(w, script)
+	return err
+}
+
+const bashCompletion = `# bash completion for muxify
+_muxify() {
+  local cur="${COMP_WORDS[COMP_CWORD]}"
+  local IFS=$'\n'
+  COMPREPLY=($(compgen -W "$(muxify __complete "${COMP_WORDS[@]:1:COMP_CWORD-1}" 2>/dev/null)" -- "$cur"))
+}
+complete -o default -F _muxify muxify
+`
+
+const zshCompletion = `#compdef muxify
+# zsh completion for muxify
+_muxify() {
+  local -a candidates
+  candidates=("${(@f)$(muxify __complete "${(@)words[2,CURRENT-1]}" 2>/dev/null)}")
+  compadd -a candidates
+}
+compdef _muxify muxify
+`
+
+const fishCompletion = `# fish completion for muxify
+function __muxify_complete
+  set -l args (commandline -opc)
+  set -e args[1]
+  muxify __complete $args 2>/dev/null
+end
+complete -c muxify -f -a '(__muxify_complete)'
+`
@@ -0,0 +1,50 @@
+package main_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/suite"
+
+	. "github.com/stroiman/muxify"
+)
+
+type CompletionTestSuite struct {
+	GomegaSuite
+	config MuxifyConfiguration
+}
+
+func TestCompletion(t *testing.T) {
+	suite.Run(t, new(CompletionTestSuite))
+}
+
+func (s *CompletionTestSuite) SetupTest() {
+	s.GomegaSuite.SetupTest()
+	s.config = MuxifyConfiguration{Projects: []Project{
+		{Name: "project-1", Tasks: map[string]Task{"test": {}, "editor": {}}},
+		{Name: "project-2"},
+	}}
+}
+
+func (s *CompletionTestSuite) TestFirstArgumentCompletesProjects() {
+	s.Expect(Complete(s.config, nil)).To(ContainElements("project-1", "project-2"))
+}
+
+func (s *CompletionTestSuite) TestCompletionCompletesShells() {
+	s.Expect(
+		Complete(s.config, []string{"completion"}),
+	).To(HaveExactElements("bash", "zsh", "fish"))
+}
+
+func (s *CompletionTestSuite) TestTaskCommandsCompleteProjectThenTask() {
+	s.Expect(
+		Complete(s.config, []string{"restart"}),
+	).To(HaveExactElements("project-1", "project-2"))
+	s.Expect(
+		Complete(s.config, []string{"restart", "project-1"}),
+	).To(HaveExactElements("editor", "test"))
+}
+
+func (s *CompletionTestSuite) TestNoCandidatesAfterProjectName() {
+	s.Expect(Complete(s.config, []string{"project-1"})).To(BeEmpty())
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"slices"
+)
+
+// Condition restricts a window or task to particular machines. All specified
+// criteria must be met.
+type Condition struct {
+	// Hostname is a glob pattern matching the machine's host name
+	Hostname string `yaml:"hostname,omitempty"`
+	// Env is the name of an environment variable that must be set
+	Env string `yaml:"env,omitempty"`
+	// Profile must match the profile selected with --profile or MUXIFY_PROFILE
+	Profile string `yaml:"profile,omitempty"`
+	// Command is a shell command that must exit with status 0. It runs in the
+	// project's working dir.
+	Command string `yaml:"command,omitempty"`
+}
+
+func (c *Condition) Holds(profile string, dir string) (bool, error) {
+	if c == nil {
+		return true, nil
+	}
+	if c.Hostname != "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return false, err
+		}
+		if ok, err := path.Match(c.Hostname, hostname); !ok || err != nil {
+			return false, err
+		}
+	}
+	if c.Env != "" {
+		if _, ok := os.LookupEnv(c.Env); !ok {
+			return false, nil
+		}
+	}
+	if c.Profile != "" && c.Profile != profile {
+		return false, nil
+	}
+	if c.Command != "" {
+		cmd := exec.Command("sh", "-c", c.Command)
+		cmd.Dir = dir
+		err := cmd.Run()
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("Condition command %q: %w", c.Command, err)
+		}
+	}
+	return true, nil
+}
+
+// ApplyConditions returns the project without the windows and tasks whose
+// `when` conditions are not met on this machine. Panes running an excluded task
+// are removed from their windows, and a window left without panes is removed.
+func (p Project) ApplyConditions() (Project, error) {
+	tasks := make(map[string]Task, len(p.Tasks))
+	for name, task := range p.Tasks {
+		ok, err := task.When.Holds(p.Profile, p.WorkingDirectory)
+		if err != nil {
+			return p, fmt.Errorf("Task %q: %w", name, err)
+		}
+		if ok {
+			tasks[name] = task
+		}
+	}
+	windows := make([]Window, 0, len(p.Windows))
+	for _, window := range p.Windows {
+		ok, err := window.When.Holds(p.Profile, p.WorkingDirectory)
+		if err != nil {
+			return p, fmt.Errorf("Window %q: %w", window.Name, err)
+		}
+		if !ok {
+			continue
+		}
+		configured := len(window.Panes)
+		window.Panes = slices.DeleteFunc(slices.Clone(window.Panes), func(taskId TaskId) bool {
+			_, defined := p.Tasks[taskId]
+			_, included := tasks[taskId]
+			return defined && !included
+		})
+		if configured > 0 && len(window.Panes) == 0 {
+			continue
+		}
+		windows = append(windows, window)
+	}
+	if p.Tasks != nil {
+		p.Tasks = tasks
+	}
+	p.Windows = windows
+	return p, nil
+}
@@ -0,0 +1,126 @@
+package main_test
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/suite"
+
+	. "github.com/stroiman/muxify"
+)
+
+type ConditionTestSuite struct {
+	GomegaSuite
+	hostname string
+}
+
+func TestCondition(t *testing.T) {
+	suite.Run(t, new(ConditionTestSuite))
+}
+
+func (s *ConditionTestSuite) SetupTest() {
+	s.GomegaSuite.SetupTest()
+	var err error
+	s.hostname, err = os.Hostname()
+	s.Expect(err).ToNot(HaveOccurred())
+}
+
+func (s *ConditionTestSuite) TestHostname() {
+	s.Expect((&Condition{Hostname: s.hostname}).Holds("", "")).To(BeTrue())
+	s.Expect((&Condition{Hostname: s.hostname[:1] + "*"}).Holds("", "")).To(BeTrue())
+	s.Expect((&Condition{Hostname: "not-" + s.hostname}).Holds("", "")).To(BeFalse())
+}
+
+func (s *ConditionTestSuite) TestEnv() {
+	s.T().Setenv("MUXIFY_TEST_CONDITION", "")
+	s.Expect((&Condition{Env: "MUXIFY_TEST_CONDITION"}).Holds("", "")).To(BeTrue())
+	s.Expect((&Condition{Env: "MUXIFY_TEST_MISSING"}).Holds("", "")).To(BeFalse())
+}
+
+func (s *ConditionTestSuite) TestProfile() {
+	s.Expect((&Condition{Profile: "office"}).Holds("office", "")).To(BeTrue())
+	s.Expect((&Condition{Profile: "office"}).Holds("laptop", "")).To(BeFalse())
+	s.Expect((&Condition{Profile: "office"}).Holds("", "")).To(BeFalse())
+}
+
+func (s *ConditionTestSuite) TestCommand() {
+	s.Expect((&Condition{Command: "true"}).Holds("", "")).To(BeTrue())
+	s.Expect((&Condition{Command: "exit 1"}).Holds("", "")).To(BeFalse())
+}
+
+func (s *ConditionTestSuite) TestCommandRunsInDir() {
+	dir := s.T().TempDir()
+	s.Expect(os.WriteFile(path.Join(dir, "marker"), nil, 0600)).To(Succeed())
+	s.Expect((&Condition{Command: "test -f marker"}).Holds("", dir)).To(BeTrue())
+}
+
+func (s *ConditionTestSuite) TestAllCriteriaMustHold() {
+	s.Expect((&Condition{Profile: "office", Command: "false"}).Holds("office", "")).To(BeFalse())
+}
+
+func (s *ConditionTestSuite) TestApplyConditions() {
+	config, err := Decode(strings.NewReader(`
+projects:
+  - name: project
+    windows:
+      - name: Editor
+        panes: [editor, postgres]
+      - name: Storybook
+        when:
+          profile: office
+        panes: [storybook]
+    tasks:
+      editor:
+      storybook:
+      postgres:
+        when:
+          profile: laptop
+`))
+	s.Expect(err).ToNot(HaveOccurred())
+	project := config.Projects[0]
+
+	project.Profile = "office"
+	office, err := project.ApplyConditions()
+	s.Expect(err).ToNot(HaveOccurred())
+	s.Expect(office.Windows).To(HaveExactElements(
+		HaveField("Panes", []string{"editor"}),
+		HaveField("Panes", []string{"storybook"}),
+	))
+
+	project.Profile = "laptop"
+	laptop, err := project.ApplyConditions()
+	s.Expect(err).ToNot(HaveOccurred())
+	s.Expect(laptop.Windows).To(HaveExactElements(
+		HaveField("Panes", []string{"editor", "postgres"}),
+	))
+}
+
+func (s *ConditionTestSuite) TestWindowWithoutIncludedPanesIsRemoved() {
+	config, err := Decode(strings.NewReader(`
+projects:
+  - name: project
+    windows:
+      - name: Editor
+        panes: [editor]
+      - name: Database
+        panes: [postgres]
+      - name: Empty
+    tasks:
+      editor:
+      postgres:
+        when:
+          profile: laptop
+`))
+	s.Expect(err).ToNot(HaveOccurred())
+	project := config.Projects[0]
+	project.Profile = "office"
+	office, err := project.ApplyConditions()
+	s.Expect(err).ToNot(HaveOccurred())
+	s.Expect(office.Windows).To(HaveExactElements(
+		HaveField("Name", "Editor"),
+		HaveField("Name", "Empty"),
+	))
+}
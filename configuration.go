@@ -1,17 +1,25 @@
 package main
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
 	"io"
 	"io/fs"
 	"os"
 	"path"
+	"slices"
+	"strings"
 
+	"github.com/BurntSushi/toml"
 	"gopkg.in/yaml.v3"
 )
 
 type MuxifyConfiguration struct {
-	Projects []Project
+	Include   []string            `yaml:"include,omitempty"`
+	Templates map[string]Template `yaml:"templates,omitempty"`
+	Groups    map[string]Group    `yaml:"groups,omitempty"`
+	Projects  []Project
 }
 
 func (c MuxifyConfiguration) GetProject(name string) (Project, bool) {
@@ -24,16 +32,60 @@ func (c MuxifyConfiguration) GetProject(name string) (Project, bool) {
 }
 
 func Decode(reader io.Reader) (config MuxifyConfiguration, err error) {
+	config, err = decode(reader, ".yaml")
+	if err == nil {
+		err = config.resolve()
+	}
+	return
+}
+
+// configurationFormats are the file extensions of supported configuration
+// files.
+var configurationFormats = []string{".yaml", ".yml", ".json", ".toml"}
+
+// decode parses a single configuration file without resolving templates, as a
+// template may be defined in a different file than the projects using it. The
+// format is given by the file extension. JSON is a subset of YAML, and TOML is
+// converted to YAML, so the configuration types only need YAML annotations.
+func decode(reader io.Reader, format string) (config MuxifyConfiguration, err error) {
+	switch format {
+	case ".yaml", ".yml", ".json":
+	case ".toml":
+		var data map[string]any
+		if _, err = toml.NewDecoder(reader).Decode(&data); err != nil {
+			return
+		}
+		var yamlData []byte
+		if yamlData, err = yaml.Marshal(data); err != nil {
+			return
+		}
+		reader = bytes.NewReader(yamlData)
+	default:
+		return config, fmt.Errorf("Unsupported configuration format: %s", format)
+	}
 	decoder := yaml.NewDecoder(reader)
 	err = decoder.Decode(&config)
+	return
+}
+
+// resolve applies templates to the parsed configuration. Variables are
+// interpolated by Project.Resolve, only for the projects being used, as
+// interpolation may run commands.
+func (config *MuxifyConfiguration) resolve() error {
 	for pi, p := range config.Projects {
-		p.WorkingDirectory = os.ExpandEnv(p.WorkingDirectory)
+		p, err := config.applyTemplate(p)
+		if err != nil {
+			return fmt.Errorf("Project %q: %w", p.Name, err)
+		}
 		for wi := range p.Windows {
 			p.Windows[wi].EnsureValid()
 		}
 		config.Projects[pi] = p
 	}
-	return
+	if err := config.validateBindings(); err != nil {
+		return err
+	}
+	return config.validateGroups()
 }
 
 type OS interface {
@@ -41,23 +93,195 @@ type OS interface {
 	LookupEnv(key string) (string, bool)
 }
 
+// ReadConfiguration loads projects.yaml as well as any configuration file in
+// the projects.d folder of the configuration directory. Configuration files can
+// be YAML, JSON, or TOML, based on the file extension. Each file can include
+// other files using the `include` key. A project must only be defined once
+// across all files.
 func ReadConfiguration(os OS) (config MuxifyConfiguration, err error) {
-	dir, err := getConfigDir(os)
+	config, _, err = ReadConfigurationFiles(os)
+	return
+}
+
+// ReadConfigurationFiles reads the configuration like ReadConfiguration, also
+// returning the paths of the files read.
+func ReadConfigurationFiles(os OS) (config MuxifyConfiguration, files []string, err error) {
+	dir, err := getAppConfigDirPath(os)
 	if err != nil {
 		return
 	}
-	file, err := dir.Open("projects.yaml")
+	loader := configLoader{
+		os:              os,
+		sources:         make(map[string]string),
+		templateSources: make(map[string]string),
+		groupSources:    make(map[string]string),
+	}
+	var rootFiles, dropIns []string
+	for _, format := range configurationFormats {
+		if rootFile := path.Join(dir, "projects"+format); fileExists(os, rootFile) {
+			rootFiles = append(rootFiles, rootFile)
+		}
+		var matches []string
+		if matches, err = fs.Glob(os.Dir(dir), "projects.d/*"+format); err != nil {
+			return
+		}
+		dropIns = append(dropIns, matches...)
+	}
+	slices.Sort(dropIns)
+	if len(rootFiles) == 0 && len(dropIns) == 0 {
+		// Fail with a file not found error for the default file
+		rootFiles = []string{path.Join(dir, "projects.yaml")}
+	}
+	for _, rootFile := range rootFiles {
+		if err == nil {
+			err = loader.loadFile(rootFile)
+		}
+	}
+	for _, dropIn := range dropIns {
+		if err == nil {
+			err = loader.loadFile(path.Join(dir, dropIn))
+		}
+	}
 	if err == nil {
-		defer func() {
-			closeErr := file.Close()
-			if err == nil {
-				err = closeErr
+		err = loader.config.resolve()
+	}
+	return loader.config, loader.visited, err
+}
+
+type configLoader struct {
+	os     OS
+	config MuxifyConfiguration
+	// sources maps project names to the file they were defined in
+	sources map[string]string
+	// templateSources maps template names to the file they were defined in
+	templateSources map[string]string
+	// groupSources maps group names to the file they were defined in
+	groupSources map[string]string
+	visited      []string
+}
+
+func (l *configLoader) loadFile(filePath string) (err error) {
+	if slices.Contains(l.visited, filePath) {
+		return nil
+	}
+	l.visited = append(l.visited, filePath)
+	file, err := l.os.Dir(path.Dir(filePath)).Open(path.Base(filePath))
+	if err != nil {
+		return
+	}
+	defer func() {
+		closeErr := file.Close()
+		if err == nil {
+			err = closeErr
+		}
+	}()
+	config, err := decode(file, path.Ext(filePath))
+	if err != nil {
+		return fmt.Errorf("%s: %w", filePath, err)
+	}
+	for name, template := range config.Templates {
+		if source, ok := l.templateSources[name]; ok {
+			return fmt.Errorf(
+				"Template %q is defined in both %s and %s", name, source, filePath)
+		}
+		if l.config.Templates == nil {
+			l.config.Templates = make(map[string]Template)
+		}
+		l.templateSources[name] = filePath
+		l.config.Templates[name] = template
+	}
+	for name, group := range config.Groups {
+		if source, ok := l.groupSources[name]; ok {
+			return fmt.Errorf(
+				"Group %q is defined in both %s and %s", name, source, filePath)
+		}
+		if l.config.Groups == nil {
+			l.config.Groups = make(map[string]Group)
+		}
+		l.groupSources[name] = filePath
+		l.config.Groups[name] = group
+	}
+	for _, p := range config.Projects {
+		if source, ok := l.sources[p.Name]; ok {
+			return fmt.Errorf(
+				"Project %q is defined in both %s and %s", p.Name, source, filePath)
+		}
+		l.sources[p.Name] = filePath
+		p.configDir = path.Dir(filePath)
+		l.config.Projects = append(l.config.Projects, p)
+	}
+	for _, include := range config.Include {
+		var files []string
+		if files, err = l.resolveInclude(path.Dir(filePath), include); err != nil {
+			return fmt.Errorf("%s: %w", filePath, err)
+		}
+		for _, f := range files {
+			if err = l.loadFile(f); err != nil {
+				return
 			}
-		}()
-		config, err = Decode(file)
+		}
 	}
 	return
 }
+
+// resolveInclude expands `~` and environment variables in an include
+// directive, and returns the matching files. A relative include is resolved
+// relative to the directory of the including file.
+func (l *configLoader) resolveInclude(dir string, include string) ([]string, error) {
+	pattern, err := expandPath(l.os, include)
+	if err != nil {
+		return nil, err
+	}
+	if !path.IsAbs(pattern) {
+		pattern = path.Join(dir, pattern)
+	}
+	if !strings.ContainsAny(pattern, globChars) {
+		return []string{pattern}, nil
+	}
+	// Split the pattern into a static base path, and the part containing glob
+	// characters, as fs.Glob works on a file system relative to a base.
+	segments := strings.Split(pattern, "/")
+	base := "/"
+	for i, segment := range segments {
+		if strings.ContainsAny(segment, globChars) {
+			matches, err := fs.Glob(l.os.Dir(base), path.Join(segments[i:]...))
+			for j, match := range matches {
+				matches[j] = path.Join(base, match)
+			}
+			return matches, err
+		}
+		base = path.Join(base, segment)
+	}
+	return nil, nil
+}
+
+const globChars = "*?["
+
+// expandPath expands a leading `~` to the user's home dir as well as
+// environment variables.
+func expandPath(os OS, p string) (string, error) {
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		home, ok := os.LookupEnv("HOME")
+		if !ok {
+			return "", errors.New("Home dir not configured")
+		}
+		p = home + p[1:]
+	}
+	return expandEnv(os, p), nil
+}
+
+func expandEnv(o OS, s string) string {
+	return os.Expand(s, func(key string) string {
+		value, _ := o.LookupEnv(key)
+		return value
+	})
+}
+
+func fileExists(os OS, filePath string) bool {
+	_, err := fs.Stat(os.Dir(path.Dir(filePath)), path.Base(filePath))
+	return err == nil
+}
+
 func getConfigDirPath(os OS) (string, error) {
 	if configDir, configDirFound := os.LookupEnv("XDG_CONFIG_HOME"); configDirFound {
 		return configDir, nil
@@ -68,6 +292,11 @@ func getConfigDirPath(os OS) (string, error) {
 	return "", errors.New("Home dir not configured")
 }
 
+func getAppConfigDirPath(os OS) (string, error) {
+	configDir, err := getConfigDirPath(os)
+	return path.Join(configDir, getAppName(os)), err
+}
+
 func getAppName(os OS) string {
 	if appName, ok := os.LookupEnv("MUXIFY_APPNAME"); ok {
 		return appName
@@ -75,12 +304,3 @@ func getAppName(os OS) string {
 		return "muxify"
 	}
 }
-
-func getConfigDir(os OS) (dir fs.FS, err error) {
-	configDir, err := getConfigDirPath(os)
-	if err == nil {
-		appName := getAppName(os)
-		dir = os.Dir(path.Join(configDir, appName))
-	}
-	return
-}
@@ -42,15 +42,12 @@ type ConfigurationTestSuite struct {
 	projectsConfigFile *fstest.MapFile
 }
 
-func (s *ConfigurationTestSuite) SetupSuite() {
+func (s *ConfigurationTestSuite) SetupTest() {
+	s.GomegaSuite.SetupTest()
 	s.projectsConfigFile = &fstest.MapFile{
 		Data: []byte(example_config),
 		Mode: fs.ModePerm,
 	}
-}
-
-func (s *ConfigurationTestSuite) SetupTest() {
-	s.GomegaSuite.SetupTest()
 	s.fakeOs = FakeOS{
 		fstest.MapFS{},
 		map[string]string{"HOME": "/users/foo"},
@@ -93,7 +90,7 @@ func (s *DefaultConfigSuiteTestSuite) TestDeserializeFullConfiguration() {
 				"dev": {},
 			},
 		}}}
-	s.Expect(project).To(BeComparableTo(expected, cmpopts.IgnoreUnexported(Window{})))
+	s.Expect(project).To(BeComparableTo(expected, cmpopts.IgnoreUnexported(Project{}, Window{}, Task{})))
 }
 
 func (s *DefaultConfigSuiteTestSuite) TestExpandEnvVars() {
@@ -101,12 +98,177 @@ func (s *DefaultConfigSuiteTestSuite) TestExpandEnvVars() {
 	s.projectsConfigFile.Data = []byte(`projects:
   - name: project-1
     working_dir: $MUX_TEST_VALUE/work`)
-	projects, err := ReadConfiguration(s.fakeOs)
+	config, err := ReadConfiguration(s.fakeOs)
 	s.Expect(err).ToNot(HaveOccurred())
-	expected := MuxifyConfiguration{
-		Projects: []Project{{Name: "project-1", WorkingDirectory: "/user/foo/work"}},
+	project, err := config.Projects[0].Resolve()
+	s.Expect(err).ToNot(HaveOccurred())
+	expected := Project{Name: "project-1", WorkingDirectory: "/user/foo/work"}
+	s.Expect(project).To(BeComparableTo(expected, cmpopts.IgnoreUnexported(Project{}, Window{}, Task{})))
+}
+
+func (s *DefaultConfigSuiteTestSuite) TestWindowId() {
+	s.projectsConfigFile.Data = []byte(`projects:
+  - name: project-1
+    windows:
+      - id: editor
+        name: Code
+      - name: Logs`)
+	config, err := ReadConfiguration(s.fakeOs)
+	s.Expect(err).ToNot(HaveOccurred())
+	project, _ := config.GetProject("project-1")
+	s.Expect(project.Windows).To(HaveExactElements(
+		And(HaveField("StableId", "editor"), HaveField("Name", "Code")),
+		And(HaveField("StableId", ""), HaveField("Name", "Logs")),
+	))
+}
+
+func (s *DefaultConfigSuiteTestSuite) TestLoadProjectsFromProjectsDir() {
+	s.fakeOs.files["/users/foo/.config/muxify/projects.d/a.yaml"] = &fstest.MapFile{
+		Data: []byte("projects:\n  - name: Project 2"),
 	}
-	s.Expect(projects).To(BeComparableTo(expected, cmpopts.IgnoreUnexported(Window{})))
+	config, err := ReadConfiguration(s.fakeOs)
+	s.Expect(err).ToNot(HaveOccurred())
+	s.Expect(config.ProjectNames()).To(HaveExactElements("Project 1", "Project 2"))
+}
+
+func (s *DefaultConfigSuiteTestSuite) TestProjectsDirWithoutProjectsFile() {
+	delete(s.fakeOs.files, "/users/foo/.config/muxify/projects.yaml")
+	s.fakeOs.files["/users/foo/.config/muxify/projects.d/a.yaml"] = &fstest.MapFile{
+		Data: []byte("projects:\n  - name: Project 2"),
+	}
+	config, err := ReadConfiguration(s.fakeOs)
+	s.Expect(err).ToNot(HaveOccurred())
+	s.Expect(config.ProjectNames()).To(HaveExactElements("Project 2"))
+}
+
+func (s *DefaultConfigSuiteTestSuite) TestRelativeWorkingDirIsRelativeToFile() {
+	s.fakeOs.files["/users/foo/.config/muxify/projects.d/a.yaml"] = &fstest.MapFile{
+		Data: []byte("projects:\n  - name: Project 2\n    working_dir: ../src\n" +
+			"  - name: Project 3\n    working_dir: /src"),
+	}
+	config, err := ReadConfiguration(s.fakeOs)
+	s.Expect(err).ToNot(HaveOccurred())
+	project2, _ := config.GetProject("Project 2")
+	project3, _ := config.GetProject("Project 3")
+	project2, _ = project2.Resolve()
+	project3, _ = project3.Resolve()
+	s.Expect(project2.WorkingDirectory).To(Equal("/users/foo/.config/muxify/src"))
+	s.Expect(project3.WorkingDirectory).To(Equal("/src"))
+}
+
+func (s *DefaultConfigSuiteTestSuite) TestProjectDirIsResolvedBeforeInterpolation() {
+	s.fakeOs.files["/users/foo/.config/muxify/projects.d/a.yaml"] = &fstest.MapFile{
+		Data: []byte(`projects:
+  - name: Project 2
+    working_dir: ../src
+    tasks:
+      build:
+        commands:
+          - cd {{project.dir}}`),
+	}
+	config, err := ReadConfiguration(s.fakeOs)
+	s.Expect(err).ToNot(HaveOccurred())
+	project, _ := config.GetProject("Project 2")
+	project, err = project.Resolve()
+	s.Expect(err).ToNot(HaveOccurred())
+	s.Expect(project.Tasks["build"].Commands).To(HaveExactElements("cd /users/foo/.config/muxify/src"))
+}
+
+func (s *DefaultConfigSuiteTestSuite) TestIncludeGlobWithHomeDir() {
+	s.projectsConfigFile.Data = []byte(`include:
+  - ~/src/*/muxify.yaml
+  - $SHARED/team.yaml
+projects:
+  - name: Project 1`)
+	s.fakeOs.env["SHARED"] = "/shared"
+	s.fakeOs.files["/users/foo/src/a/muxify.yaml"] = &fstest.MapFile{
+		Data: []byte("projects:\n  - name: Project 2"),
+	}
+	s.fakeOs.files["/users/foo/src/b/muxify.yaml"] = &fstest.MapFile{
+		Data: []byte("projects:\n  - name: Project 3"),
+	}
+	s.fakeOs.files["/shared/team.yaml"] = &fstest.MapFile{
+		Data: []byte("projects:\n  - name: Project 4"),
+	}
+	config, err := ReadConfiguration(s.fakeOs)
+	s.Expect(err).ToNot(HaveOccurred())
+	s.Expect(config.ProjectNames()).To(
+		HaveExactElements("Project 1", "Project 2", "Project 3", "Project 4"))
+}
+
+func (s *DefaultConfigSuiteTestSuite) TestRelativeIncludeIsRelativeToFile() {
+	s.projectsConfigFile.Data = []byte("include: [shared/team.yaml]")
+	s.fakeOs.files["/users/foo/.config/muxify/shared/team.yaml"] = &fstest.MapFile{
+		Data: []byte("projects:\n  - name: Project 2"),
+	}
+	config, err := ReadConfiguration(s.fakeOs)
+	s.Expect(err).ToNot(HaveOccurred())
+	s.Expect(config.ProjectNames()).To(HaveExactElements("Project 2"))
+}
+
+func (s *DefaultConfigSuiteTestSuite) TestMissingIncludeIsAnError() {
+	s.projectsConfigFile.Data = []byte("include: [missing.yaml]")
+	_, err := ReadConfiguration(s.fakeOs)
+	s.Expect(err).To(HaveOccurred())
+}
+
+func (s *DefaultConfigSuiteTestSuite) TestTemplateFromOtherFile() {
+	s.projectsConfigFile.Data = []byte("projects:\n  - name: Project 1\n    extends: t")
+	s.fakeOs.files["/users/foo/.config/muxify/projects.d/templates.yaml"] = &fstest.MapFile{
+		Data: []byte("templates:\n  t:\n    tasks:\n      editor:"),
+	}
+	config, err := ReadConfiguration(s.fakeOs)
+	s.Expect(err).ToNot(HaveOccurred())
+	project, _ := config.GetProject("Project 1")
+	s.Expect(project.TaskNames()).To(HaveExactElements("editor"))
+}
+
+func (s *DefaultConfigSuiteTestSuite) TestJSONAndTOMLFiles() {
+	s.fakeOs.files["/users/foo/.config/muxify/projects.d/a.json"] = &fstest.MapFile{
+		Data: []byte(`{"projects": [{"name": "Project 2", "working_dir": "/json"}]}`),
+	}
+	s.fakeOs.files["/users/foo/.config/muxify/projects.d/b.toml"] = &fstest.MapFile{
+		Data: []byte(`
+[[projects]]
+name = "Project 3"
+working_dir = "/toml"
+
+[[projects.windows]]
+name = "Editor"
+panes = ["editor"]
+for_each = "packages/*"
+
+[projects.tasks.editor]
+commands = ["nvim ."]
+`),
+	}
+	config, err := ReadConfiguration(s.fakeOs)
+	s.Expect(err).ToNot(HaveOccurred())
+	s.Expect(config.ProjectNames()).To(HaveExactElements("Project 1", "Project 2", "Project 3"))
+	json, _ := config.GetProject("Project 2")
+	s.Expect(json.WorkingDirectory).To(Equal("/json"))
+	toml, _ := config.GetProject("Project 3")
+	s.Expect(toml).To(BeComparableTo(Project{
+		Name:             "Project 3",
+		WorkingDirectory: "/toml",
+		Windows: []Window{{
+			Name:    "Editor",
+			Panes:   []string{"editor"},
+			ForEach: &ForEach{Glob: "packages/*"},
+		}},
+		Tasks: map[string]Task{"editor": {Commands: []string{"nvim ."}}},
+	}, cmpopts.IgnoreUnexported(Project{}, Window{}, Task{})))
+}
+
+func (s *DefaultConfigSuiteTestSuite) TestDuplicateProjectNamesBothFiles() {
+	s.fakeOs.files["/users/foo/.config/muxify/projects.d/dup.yaml"] = &fstest.MapFile{
+		Data: []byte("projects:\n  - name: Project 1"),
+	}
+	_, err := ReadConfiguration(s.fakeOs)
+	s.Expect(err).To(MatchError(And(
+		ContainSubstring("/users/foo/.config/muxify/projects.yaml"),
+		ContainSubstring("/users/foo/.config/muxify/projects.d/dup.yaml"),
+	)))
 }
 
 type XDGOverwrittenTestSuite struct {
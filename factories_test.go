@@ -90,6 +90,30 @@ func TaskCommands(commands ...string) CreatePaneOption {
 	}
 }
 
+func TaskExec(program string) CreatePaneOption {
+	return CreatePaneOption{
+		UpdateTask: func(task *Task) { task.Exec = program },
+	}
+}
+
+func TaskShell(shell string) CreatePaneOption {
+	return CreatePaneOption{
+		UpdateTask: func(task *Task) { task.Shell = shell },
+	}
+}
+
+func TaskPrompt(prompt string) CreatePaneOption {
+	return CreatePaneOption{
+		UpdateTask: func(task *Task) { task.Prompt = prompt },
+	}
+}
+
+func TaskKeepOpen() CreatePaneOption {
+	return CreatePaneOption{
+		UpdateTask: func(task *Task) { task.KeepOpen = true },
+	}
+}
+
 func (s *TestProject) CreatePane(paneName string, options ...CreatePaneOption) TaskId {
 	task := Task{}
 	if s.Tasks == nil {
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ForEach expands a window definition into one window per match, either
+// matching a glob relative to the project's working dir, or the lines written
+// to standard out by a shell command.
+type ForEach struct {
+	Glob    string `yaml:"glob,omitempty"`
+	Command string `yaml:"command,omitempty"`
+}
+
+// forEachWindowOption is the tmux window option recording which window
+// definition a window was expanded from, allowing windows to be pruned when
+// they no longer match.
+const forEachWindowOption = "@muxify_for_each"
+
+// forEachParams are the parameters available in a window expanded from a
+// for_each. They are left untouched when substituting template parameters.
+var forEachParams = []string{"Name", "Dir"}
+
+// forEachKey identifies the window definition with a for_each that windows are
+// expanded from, even when the definition has no name. The key is never empty,
+// so windows without the option never match it.
+func (w Window) forEachKey() string {
+	h := fnv.New64a()
+	for _, s := range []string{w.StableId, w.Name, w.ForEach.Glob, w.ForEach.Command} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	return fmt.Sprintf("%x", h.Sum64())
+}
+
+func (f *ForEach) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		f.Glob = node.Value
+		return nil
+	}
+	type plain ForEach
+	return node.Decode((*plain)(f))
+}
+
+func (f ForEach) matches(dir string) ([]string, error) {
+	if f.Command != "" {
+		cmd := exec.Command("sh", "-c", f.Command)
+		cmd.Dir = dir
+		output, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("for_each command %q failed: %w", f.Command, err)
+		}
+		return getLines(output), nil
+	}
+	if f.Glob == "" {
+		return nil, errors.New("for_each requires a glob or a command")
+	}
+	matches, err := filepath.Glob(filepath.Join(dir, f.Glob))
+	if err != nil {
+		return nil, err
+	}
+	result := make([]string, 0, len(matches))
+	for _, match := range matches {
+		if info, err := os.Stat(match); err == nil && info.IsDir() {
+			rel, _ := filepath.Rel(dir, match)
+			result = append(result, rel)
+		}
+	}
+	return result, nil
+}
+
+// ExpandWindows returns the project where each window with a for_each is
+// replaced by one window per match. The tasks in the window are copied for
+// each match, having `{{.Name}}` and `{{.Dir}}` substituted, and the working
+// dir defaulting to the match. The pane of a copied task is titled
+// "<task>/<name>", and the id of a window with an id is "<id>/<name>".
+func (p Project) ExpandWindows() (Project, error) {
+	if !slices.ContainsFunc(p.Windows, func(w Window) bool { return w.ForEach != nil }) {
+		return p, nil
+	}
+	windows := make([]Window, 0, len(p.Windows))
+	tasks := mergeMaps(p.Tasks, nil)
+	for _, window := range p.Windows {
+		if window.ForEach == nil {
+			windows = append(windows, window)
+			continue
+		}
+		matches, err := window.ForEach.matches(p.WorkingDirectory)
+		if err != nil {
+			return p, fmt.Errorf("Window %q: %w", window.Name, err)
+		}
+		for _, match := range matches {
+			params := map[string]string{"Name": path.Base(match), "Dir": match}
+			expanded, err := window.expand(p, params, tasks)
+			if err != nil {
+				return p, fmt.Errorf("Window %q: %w", window.Name, err)
+			}
+			windows = append(windows, expanded)
+		}
+	}
+	p.Windows = windows
+	p.Tasks = tasks
+	return p, nil
+}
+
+func (w Window) expand(p Project, params map[string]string, tasks map[string]Task) (Window, error) {
+	name := params["Name"]
+	result, err := w.substitute(params)
+	if err != nil {
+		return result, err
+	}
+	if w.Name == "" || !strings.Contains(w.Name, "{{") {
+		result.Name = name
+	}
+	result.id = WindowId{}
+	result.EnsureValid()
+	result.ForEach = nil
+	result.expandedFrom = w.forEachKey()
+	if w.StableId != "" {
+		result.StableId = w.StableId + "/" + name
+	}
+	result.Panes = make([]TaskId, len(w.Panes))
+	for i, taskId := range w.Panes {
+		task, err := p.Tasks[taskId].substitute(params)
+		if err != nil {
+			return result, fmt.Errorf("Task %q: %w", taskId, err)
+		}
+		if task.WorkingDirectory == "" {
+			task.WorkingDirectory = params["Dir"]
+		}
+		expandedId := taskId + "/" + name
+		tasks[expandedId] = task
+		result.Panes[i] = expandedId
+	}
+	return result, nil
+}
+
+// prunedForEachWindows returns the keys of the window definitions with a
+// for_each, having pruning enabled.
+func (p Project) prunedForEachWindows() []string {
+	var result []string
+	for _, w := range p.Windows {
+		if w.ForEach != nil && w.Prune {
+			result = append(result, w.forEachKey())
+		}
+	}
+	return result
+}
+
+// pruneExpandedWindows kills windows previously expanded from a for_each with
+// pruning enabled, that no longer match.
+func (p Project) pruneExpandedWindows(
+	ctx context.Context,
+	server TmuxServer,
+	tmuxWindows TmuxWindows,
+	pruned []string,
+) error {
+	for _, tmuxWindow := range tmuxWindows {
+		if tmuxWindow.ExpandedFrom == "" || !slices.Contains(pruned, tmuxWindow.ExpandedFrom) {
+			continue
+		}
+		expected := slices.ContainsFunc(p.Windows, func(w Window) bool {
+			return w.expandedFrom == tmuxWindow.ExpandedFrom && w.Name == tmuxWindow.Name
+		})
+		if !expected {
+			if err := server.KillWindow(ctx, tmuxWindow); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
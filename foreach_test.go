@@ -0,0 +1,144 @@
+package main_test
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/suite"
+
+	. "github.com/stroiman/muxify"
+)
+
+type ForEachTestSuite struct {
+	GomegaSuite
+	dir string
+}
+
+func TestForEach(t *testing.T) {
+	suite.Run(t, new(ForEachTestSuite))
+}
+
+func (s *ForEachTestSuite) SetupTest() {
+	s.GomegaSuite.SetupTest()
+	s.dir = s.T().TempDir()
+	for _, pkg := range []string{"backend", "frontend", "shared"} {
+		s.Expect(os.MkdirAll(path.Join(s.dir, "packages", pkg), 0700)).To(Succeed())
+	}
+	s.Expect(os.WriteFile(path.Join(s.dir, "packages", "README.md"), nil, 0600)).To(Succeed())
+}
+
+func (s *ForEachTestSuite) decodeProject(config string) Project {
+	c, err := Decode(strings.NewReader(config))
+	s.Expect(err).ToNot(HaveOccurred())
+	c.Projects[0].WorkingDirectory = s.dir
+	return c.Projects[0]
+}
+
+func (s *ForEachTestSuite) TestExpandGlobIntoWindowPerDirectory() {
+	project, err := s.decodeProject(`
+projects:
+  - name: monorepo
+    windows:
+      - name: main
+        panes: [shell]
+      - for_each: packages/*
+        panes: [editor, test]
+    tasks:
+      editor:
+        commands: ["nvim ."]
+      test:
+        working_dir: "{{.Dir}}/src"
+        commands: ["echo {{.Name}}"]
+`).ExpandWindows()
+	s.Expect(err).ToNot(HaveOccurred())
+	s.Expect(project.Windows).To(HaveExactElements(
+		HaveField("Name", "main"),
+		And(HaveField("Name", "backend"), HaveField("Panes", []string{"editor/backend", "test/backend"})),
+		HaveField("Name", "frontend"),
+		HaveField("Name", "shared"),
+	))
+	s.Expect(project.Tasks["editor/frontend"]).To(Equal(
+		Task{WorkingDirectory: "packages/frontend", Commands: []string{"nvim ."}}))
+	s.Expect(project.Tasks["test/frontend"]).To(Equal(
+		Task{WorkingDirectory: "packages/frontend/src", Commands: []string{"echo frontend"}}))
+	s.Expect(project.Validate()).To(Succeed())
+}
+
+func (s *ForEachTestSuite) TestWindowNameWithParameter() {
+	project, err := s.decodeProject(`
+projects:
+  - name: monorepo
+    windows:
+      - name: "pkg-{{.Name}}"
+        for_each: packages/*end
+`).ExpandWindows()
+	s.Expect(err).ToNot(HaveOccurred())
+	s.Expect(project.Windows).To(HaveExactElements(
+		HaveField("Name", "pkg-backend"),
+		HaveField("Name", "pkg-frontend"),
+	))
+}
+
+func (s *ForEachTestSuite) TestWindowWorkingDirWithParameter() {
+	project, err := s.decodeProject(`
+projects:
+  - name: monorepo
+    windows:
+      - for_each: packages/shared
+        working_dir: "{{.Dir}}/src"
+`).ExpandWindows()
+	s.Expect(err).ToNot(HaveOccurred())
+	s.Expect(project.Windows).To(HaveExactElements(
+		And(HaveField("Name", "shared"), HaveField("WorkingDirectory", "packages/shared/src"))))
+}
+
+func (s *ForEachTestSuite) TestExecAndShellWithParameters() {
+	project, err := s.decodeProject(`
+projects:
+  - name: monorepo
+    windows:
+      - for_each: packages/shared
+        panes: [server]
+    tasks:
+      server:
+        exec: "node {{.Dir}}/server.js"
+        shell: "/bin/{{.Name}}-sh"
+`).ExpandWindows()
+	s.Expect(err).ToNot(HaveOccurred())
+	s.Expect(project.Tasks["server/shared"]).To(And(
+		HaveField("Exec", "node packages/shared/server.js"),
+		HaveField("Shell", "/bin/shared-sh"),
+	))
+}
+
+func (s *ForEachTestSuite) TestExpandCommandOutput() {
+	project, err := s.decodeProject(`
+projects:
+  - name: monorepo
+    windows:
+      - for_each:
+          command: ls -d packages/s*
+`).ExpandWindows()
+	s.Expect(err).ToNot(HaveOccurred())
+	s.Expect(project.Windows).To(HaveExactElements(HaveField("Name", "shared")))
+}
+
+func (s *ForEachTestSuite) TestForEachInTemplate() {
+	project, err := s.decodeProject(`
+templates:
+  monorepo:
+    windows:
+      - name: "{{.Prefix}}-{{.Name}}"
+        for_each: packages/shared
+projects:
+  - name: monorepo
+    extends: monorepo
+    params:
+      Prefix: pkg
+`).ExpandWindows()
+	s.Expect(err).ToNot(HaveOccurred())
+	s.Expect(project.Windows).To(HaveExactElements(HaveField("Name", "pkg-shared")))
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// Group is a set of related projects, each having their own session, that are
+// started and stopped together.
+type Group struct {
+	Projects []string
+	// Primary is the project to attach to after starting the group. Defaults to
+	// the first project.
+	Primary string `yaml:"primary,omitempty"`
+}
+
+func (g Group) PrimaryProject() string {
+	if g.Primary == "" && len(g.Projects) > 0 {
+		return g.Projects[0]
+	}
+	return g.Primary
+}
+
+func (c MuxifyConfiguration) validateGroups() error {
+	for name, group := range c.Groups {
+		if len(group.Projects) == 0 {
+			return fmt.Errorf("Group %q has no projects", name)
+		}
+		for _, projectName := range group.Projects {
+			if _, ok := c.GetProject(projectName); !ok {
+				return fmt.Errorf("Group %q: Project %q not found", name, projectName)
+			}
+		}
+		if !slices.Contains(group.Projects, group.PrimaryProject()) {
+			return fmt.Errorf("Group %q: Primary %q is not in the group", name, group.Primary)
+		}
+	}
+	return nil
+}
+
+// GetGroupProjects returns the projects of a group, the primary project first.
+// A project name is treated as a group containing only that project.
+func (c MuxifyConfiguration) GetGroupProjects(name string) ([]Project, bool) {
+	if group, ok := c.Groups[name]; ok {
+		primary, _ := c.GetProject(group.PrimaryProject())
+		result := []Project{primary}
+		for _, projectName := range group.Projects {
+			if projectName != primary.Name {
+				project, _ := c.GetProject(projectName)
+				result = append(result, project)
+			}
+		}
+		return result, true
+	}
+	if project, ok := c.GetProject(name); ok {
+		return []Project{project}, true
+	}
+	return nil, false
+}
+
+// forEachConcurrently calls f for each project concurrently, returning all
+// errors.
+func forEachConcurrently(projects []Project, f func(Project) error) error {
+	errs := make([]error, len(projects))
+	var wg sync.WaitGroup
+	for i, project := range projects {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := f(project); err != nil {
+				errs[i] = fmt.Errorf("%s: %w", project.Name, err)
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// Up starts all projects in the group concurrently, and attaches to the
+// primary project.
+func Up(ctx context.Context, runner Runner, projects []Project) error {
+	err := forEachConcurrently(projects, func(p Project) error {
+		return runner.Run(ctx, p)
+	})
+	if err != nil {
+		return err
+	}
+	return runner.Attach(ctx, projects[0].Name)
+}
+
+// Stop kills the sessions of all projects in the group. Projects that aren't
+// running are ignored.
+func Stop(ctx context.Context, runner Runner, projects []Project) error {
+	return forEachConcurrently(projects, func(p Project) error {
+		if err := runner.StopSession(ctx, p.Name); !errors.Is(err, ErrSessionNotRunning) {
+			return err
+		}
+		return nil
+	})
+}
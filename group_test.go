@@ -0,0 +1,115 @@
+package main_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/mock/gomock"
+
+	. "github.com/stroiman/muxify"
+)
+
+type GroupTestSuite struct {
+	GomegaSuite
+}
+
+func TestGroup(t *testing.T) {
+	suite.Run(t, new(GroupTestSuite))
+}
+
+var groupConfig = `
+groups:
+  product:
+    projects: [frontend, api, infra]
+    primary: api
+projects:
+  - name: frontend
+  - name: api
+  - name: infra
+`
+
+func (s *GroupTestSuite) decode(config string) MuxifyConfiguration {
+	result, err := Decode(strings.NewReader(config))
+	s.Expect(err).ToNot(HaveOccurred())
+	return result
+}
+
+func (s *GroupTestSuite) TestGroupProjectsPrimaryFirst() {
+	projects, ok := s.decode(groupConfig).GetGroupProjects("product")
+	s.Expect(ok).To(BeTrue())
+	s.Expect(projects).To(HaveExactElements(
+		HaveField("Name", "api"),
+		HaveField("Name", "frontend"),
+		HaveField("Name", "infra"),
+	))
+}
+
+func (s *GroupTestSuite) TestProjectIsAGroupOfOne() {
+	projects, ok := s.decode(groupConfig).GetGroupProjects("infra")
+	s.Expect(ok).To(BeTrue())
+	s.Expect(projects).To(HaveExactElements(HaveField("Name", "infra")))
+}
+
+func (s *GroupTestSuite) TestUnknownProjectInGroup() {
+	_, err := Decode(strings.NewReader(strings.Replace(groupConfig, "infra]", "ops]", 1)))
+	s.Expect(err).To(MatchError(ContainSubstring(`"ops"`)))
+}
+
+func (s *GroupTestSuite) TestPrimaryMustBeInGroup() {
+	_, err := Decode(strings.NewReader(strings.Replace(groupConfig, "primary: api", "primary: web", 1)))
+	s.Expect(err).To(MatchError(ContainSubstring(`"web"`)))
+}
+
+func (s *GroupTestSuite) TestUpStartsAllAndAttachesToPrimary() {
+	controller := gomock.NewController(s.T())
+	runner := NewMockRunner(controller)
+	projects, _ := s.decode(groupConfig).GetGroupProjects("product")
+	var mutex sync.Mutex
+	var started []string
+	runner.EXPECT().Run(gomock.Any(), gomock.Any()).Times(3).Do(func(_ context.Context, p Project) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		started = append(started, p.Name)
+	})
+	runner.EXPECT().Attach(gomock.Any(), "api")
+	s.Expect(Up(context.Background(), runner, projects)).To(Succeed())
+	controller.Finish()
+	s.Expect(started).To(ConsistOf("api", "frontend", "infra"))
+}
+
+func (s *GroupTestSuite) TestUpDoesNotAttachOnError() {
+	controller := gomock.NewController(s.T())
+	runner := NewMockRunner(controller)
+	projects, _ := s.decode(groupConfig).GetGroupProjects("product")
+	runner.EXPECT().Run(gomock.Any(), projectNamed("frontend")).Return(errors.New("Failure"))
+	runner.EXPECT().Run(gomock.Any(), gomock.Any()).Times(2)
+	s.Expect(Up(context.Background(), runner, projects)).To(MatchError(ContainSubstring("frontend: Failure")))
+	controller.Finish()
+}
+
+func (s *GroupTestSuite) TestStopKillsAllSessions() {
+	controller := gomock.NewController(s.T())
+	runner := NewMockRunner(controller)
+	projects, _ := s.decode(groupConfig).GetGroupProjects("product")
+	runner.EXPECT().StopSession(gomock.Any(), "api")
+	runner.EXPECT().StopSession(gomock.Any(), "frontend")
+	runner.EXPECT().StopSession(gomock.Any(), "infra")
+	s.Expect(Stop(context.Background(), runner, projects)).To(Succeed())
+	controller.Finish()
+}
+
+func (s *GroupTestSuite) TestStopIgnoresSessionsNotRunning() {
+	controller := gomock.NewController(s.T())
+	runner := NewMockRunner(controller)
+	projects, _ := s.decode(groupConfig).GetGroupProjects("product")
+	runner.EXPECT().StopSession(gomock.Any(), "api")
+	runner.EXPECT().StopSession(gomock.Any(), "frontend").Return(ErrSessionNotRunning)
+	runner.EXPECT().StopSession(gomock.Any(), "infra")
+	s.Expect(Stop(context.Background(), runner, projects)).To(Succeed())
+	controller.Finish()
+}
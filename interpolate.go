@@ -0,0 +1,340 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// interpolator expands variables in configuration values:
+//
+//   - A leading `~` is replaced with the home directory.
+//   - `$NAME`, `${NAME}`, `${NAME:-default}`, and `${NAME:?error message}` are
+//     replaced with the project's `vars`, or environment variables. Unknown
+//     variables are left untouched, so they can be expanded by the shell when
+//     running a command. `$$` is a literal `$`.
+//   - `{{project.name}}`, `{{project.dir}}`, and `{{task.name}}` are replaced
+//     with the name and working dir of the project, and the name of the task.
+//   - `$(command)` is replaced with the output of the command, if the project
+//     has enabled `command_substitution`.
+//
+// Shell commands are run by the shell, which expands variables itself, so only
+// the project's `vars` and the built-ins are replaced in them.
+type interpolator struct {
+	vars                map[string]string
+	commandSubstitution bool
+	projectName         string
+	projectDir          string
+	// taskName is the name of the task being interpolated, if any
+	taskName string
+	// shell is set when interpolating a shell command
+	shell bool
+}
+
+// shellCommandFields are the fields holding shell commands
+var shellCommandFields = map[reflect.Type][]string{
+	reflect.TypeOf(Task{}):      {"Commands", "Exec", "Shell"},
+	reflect.TypeOf(Condition{}): {"Command"},
+	reflect.TypeOf(ForEach{}):   {"Command"},
+}
+
+// uninterpolatedFields are the fields that are not interpolated, as they refer
+// to tasks, or are regular expressions.
+var uninterpolatedFields = map[reflect.Type][]string{
+	reflect.TypeOf(Window{}): {"Panes"},
+	reflect.TypeOf(Task{}):   {"Prompt"},
+}
+
+var builtinExp = regexp.MustCompile(`\{\{\s*((?:project|task)\.\w+)\s*\}\}`)
+var varNameExp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*`)
+
+func (i interpolator) lookup(name string) (string, bool) {
+	if value, ok := i.vars[name]; ok {
+		return value, true
+	}
+	if i.shell {
+		return "", false
+	}
+	return os.LookupEnv(name)
+}
+
+func (i interpolator) interpolate(s string) (string, error) {
+	if !i.shell && (s == "~" || strings.HasPrefix(s, "~/")) {
+		if home, ok := os.LookupEnv("HOME"); ok {
+			s = home + s[1:]
+		}
+	}
+	s, err := i.expandBuiltins(s)
+	if err != nil {
+		return s, err
+	}
+	var b strings.Builder
+	for len(s) > 0 {
+		index := strings.IndexByte(s, '$')
+		if index < 0 || index == len(s)-1 {
+			b.WriteString(s)
+			break
+		}
+		b.WriteString(s[:index])
+		s = s[index+1:]
+		var consumed int
+		var value string
+		switch {
+		case s[0] == '$' && i.shell:
+			value, consumed = "$$", 1
+		case s[0] == '$':
+			value, consumed = "$", 1
+		case s[0] == '{':
+			value, consumed, err = i.expandBraces(s)
+		case s[0] == '(' && i.commandSubstitution && !i.shell:
+			value, consumed, err = expandCommand(s)
+		default:
+			name := varNameExp.FindString(s)
+			consumed = len(name)
+			if v, ok := i.lookup(name); ok && name != "" {
+				value = v
+			} else {
+				value = "$" + name
+			}
+		}
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(value)
+		s = s[consumed:]
+	}
+	return b.String(), nil
+}
+
+func (i interpolator) expandBuiltins(s string) (string, error) {
+	var err error
+	result := builtinExp.ReplaceAllStringFunc(s, func(match string) string {
+		name := builtinExp.FindStringSubmatch(match)[1]
+		switch {
+		case name == "project.name":
+			return i.projectName
+		case name == "project.dir":
+			return i.projectDir
+		case name == "task.name" && i.taskName != "":
+			return i.taskName
+		case name == "task.name":
+			err = fmt.Errorf("%s can only be used in a task", match)
+		default:
+			err = fmt.Errorf("Unknown variable %s", match)
+		}
+		return match
+	})
+	return result, err
+}
+
+// expandBraces expands `{NAME...}`, the text following a `$`, returning the
+// number of bytes consumed.
+func (i interpolator) expandBraces(s string) (string, int, error) {
+	end := strings.IndexByte(s, '}')
+	if end < 0 {
+		return "", 0, fmt.Errorf("Missing '}' in %q", "$"+s)
+	}
+	expr := s[1:end]
+	literal := "$" + s[:end+1]
+	name, operand, operator := expr, "", ""
+	for _, op := range []string{":-", ":?"} {
+		if before, after, found := strings.Cut(expr, op); found {
+			name, operand, operator = before, after, op
+			break
+		}
+	}
+	value, ok := i.lookup(name)
+	if !ok && i.shell {
+		return literal, end + 1, nil
+	}
+	if ok && value != "" {
+		return value, end + 1, nil
+	}
+	switch operator {
+	case ":-":
+		return operand, end + 1, nil
+	case ":?":
+		if operand == "" {
+			operand = "not set"
+		}
+		return "", 0, fmt.Errorf("%s: %s", name, operand)
+	}
+	if ok {
+		return value, end + 1, nil
+	}
+	return literal, end + 1, nil
+}
+
+// expandCommand runs the command in `(command)`, the text following a `$`,
+// returning the number of bytes consumed.
+func expandCommand(s string) (string, int, error) {
+	depth := 0
+	for index, c := range s {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		}
+		if depth == 0 {
+			command := s[1:index]
+			output, err := exec.Command("sh", "-c", command).Output()
+			if err != nil {
+				return "", 0, fmt.Errorf("Command %q failed: %w", command, err)
+			}
+			return strings.TrimRight(string(output), "\n"), index + 1, nil
+		}
+	}
+	return "", 0, fmt.Errorf("Missing ')' in %q", "$"+s)
+}
+
+// interpolatedSkipped are the project fields that are not interpolated, as they
+// identify the project, have already been applied, or aren't configuration.
+var interpolatedSkipped = []string{
+	"Name", "Extends", "Params", "Vars", "Profile", "Saved", "FocusTask",
+}
+
+// Resolve returns the project with variables interpolated, ready to be
+// started. The configuration is only resolved for the projects being used, as
+// `$(command)` runs commands.
+func (p Project) Resolve() (Project, error) {
+	resolved, err := p.interpolate()
+	if err != nil {
+		return p, fmt.Errorf("Project %q: %w", p.Name, err)
+	}
+	return resolved, nil
+}
+
+// interpolate expands variables in all string fields of the project. Errors
+// include the path of the field, e.g., `tasks.test.commands[0]`.
+func (p Project) interpolate() (Project, error) {
+	i := interpolator{
+		commandSubstitution: p.CommandSubstitution,
+		projectName:         strings.TrimSuffix(p.Name, worktreeSeparator+p.worktree),
+		vars:                make(map[string]string, len(p.Vars)),
+	}
+	for name, value := range p.Vars {
+		expanded, err := interpolator{}.interpolate(value)
+		if err != nil {
+			return p, fmt.Errorf("vars.%s: %w", name, err)
+		}
+		i.vars[name] = expanded
+	}
+	dir, err := i.interpolate(p.WorkingDirectory)
+	if err != nil {
+		return p, fmt.Errorf("working_dir: %w", err)
+	}
+	// A relative project dir is relative to the configuration file. Window and
+	// task dirs are in turn relative to the project dir.
+	if dir != "" && p.configDir != "" {
+		dir = resolveDir(p.configDir, dir)
+	}
+	if p.worktree != "" {
+		if dir, err = worktreeDir(dir, p.worktree); err != nil {
+			return p, err
+		}
+	}
+	p.WorkingDirectory = dir
+	p.configDir = ""
+	i.projectDir = dir
+
+	result := reflect.ValueOf(&p).Elem()
+	t := result.Type()
+	for index := 0; index < t.NumField(); index++ {
+		field := t.Field(index)
+		if !field.IsExported() || slices.Contains(interpolatedSkipped, field.Name) ||
+			field.Name == "WorkingDirectory" {
+			continue
+		}
+		value, err := i.interpolateValue(result.Field(index), yamlName(field))
+		if err != nil {
+			return p, err
+		}
+		result.Field(index).Set(value)
+	}
+	return p, nil
+}
+
+// interpolateValue returns a copy of the value with all strings interpolated,
+// so slices and maps shared with other projects, e.g., from a template, are not
+// modified.
+func (i interpolator) interpolateValue(v reflect.Value, path string) (reflect.Value, error) {
+	switch v.Kind() {
+	case reflect.String:
+		s, err := i.interpolate(v.String())
+		if err != nil {
+			return v, fmt.Errorf("%s: %w", path, err)
+		}
+		return reflect.ValueOf(s).Convert(v.Type()), nil
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v, nil
+		}
+		elem, err := i.interpolateValue(v.Elem(), path)
+		result := reflect.New(v.Type().Elem())
+		result.Elem().Set(elem)
+		return result, err
+	case reflect.Slice:
+		if v.IsNil() {
+			return v, nil
+		}
+		result := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for index := 0; index < v.Len(); index++ {
+			elem, err := i.interpolateValue(v.Index(index), fmt.Sprintf("%s[%d]", path, index))
+			if err != nil {
+				return v, err
+			}
+			result.Index(index).Set(elem)
+		}
+		return result, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return v, nil
+		}
+		result := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key := iter.Key().String()
+			elemInterpolator := i
+			if v.Type().Elem() == reflect.TypeOf(Task{}) {
+				elemInterpolator.taskName = key
+			}
+			elem, err := elemInterpolator.interpolateValue(iter.Value(), path+"."+key)
+			if err != nil {
+				return v, err
+			}
+			result.SetMapIndex(iter.Key(), elem)
+		}
+		return result, nil
+	case reflect.Struct:
+		result := reflect.New(v.Type()).Elem()
+		result.Set(v)
+		for index := 0; index < v.NumField(); index++ {
+			field := v.Type().Field(index)
+			if !field.IsExported() || slices.Contains(uninterpolatedFields[v.Type()], field.Name) {
+				continue
+			}
+			fieldInterpolator := i
+			fieldInterpolator.shell = slices.Contains(shellCommandFields[v.Type()], field.Name)
+			elem, err := fieldInterpolator.interpolateValue(v.Field(index), path+"."+yamlName(field))
+			if err != nil {
+				return v, err
+			}
+			result.Field(index).Set(elem)
+		}
+		return result, nil
+	}
+	return v, nil
+}
+
+func yamlName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+	if name == "" {
+		name = strings.ToLower(field.Name)
+	}
+	return name
+}
@@ -0,0 +1,160 @@
+package main_test
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/suite"
+
+	. "github.com/stroiman/muxify"
+)
+
+type InterpolateTestSuite struct {
+	GomegaSuite
+}
+
+func TestInterpolate(t *testing.T) {
+	suite.Run(t, new(InterpolateTestSuite))
+}
+
+func (s *InterpolateTestSuite) SetupTest() {
+	s.GomegaSuite.SetupTest()
+	s.T().Setenv("HOME", "/users/foo")
+	s.T().Setenv("MUX_TEST_HOST", "localhost")
+	s.T().Setenv("MUX_TEST_EMPTY", "")
+}
+
+func (s *InterpolateTestSuite) decodeProject(config string) Project {
+	project, err := s.resolveProject(config)
+	s.Expect(err).ToNot(HaveOccurred())
+	return project
+}
+
+func (s *InterpolateTestSuite) resolveProject(config string) (Project, error) {
+	c, err := Decode(strings.NewReader(config))
+	s.Expect(err).ToNot(HaveOccurred())
+	return c.Projects[0].Resolve()
+}
+
+func (s *InterpolateTestSuite) TestHomeDir() {
+	project := s.decodeProject(`
+projects:
+  - name: p
+    working_dir: ~/src/p
+    tasks:
+      t:
+        working_dir: ~/other`)
+	s.Expect(project.WorkingDirectory).To(Equal("/users/foo/src/p"))
+	s.Expect(project.Tasks["t"].WorkingDirectory).To(Equal("/users/foo/other"))
+}
+
+func (s *InterpolateTestSuite) TestEnvVarsWithDefaults() {
+	project := s.decodeProject(`
+projects:
+  - name: p
+    windows:
+      - name: $MUX_TEST_HOST:${MUX_TEST_PORT:-3000}
+      - name: ${MUX_TEST_EMPTY:-default}`)
+	s.Expect(project.Windows).To(HaveExactElements(
+		HaveField("Name", "localhost:3000"),
+		HaveField("Name", "default"),
+	))
+}
+
+func (s *InterpolateTestSuite) TestShellCommandsAreLeftForTheShell() {
+	project := s.decodeProject(`
+projects:
+  - name: p
+    command_substitution: true
+    vars:
+      PORT: "8080"
+    windows:
+      - name: w
+        when:
+          command: test -d ~/$MUX_TEST_HOST
+        panes: [t]
+    tasks:
+      t:
+        prompt: \$$
+        commands:
+          - for f in *; do echo $f ${f%.txt} $1; done
+          - echo $(date) $$ $PWD ${MUX_TEST_HOST:-other} ~
+          - serve --port $PORT`)
+	s.Expect(project.Windows[0].When.Command).To(Equal("test -d ~/$MUX_TEST_HOST"))
+	s.Expect(project.Tasks["t"].Prompt).To(Equal(`\$$`))
+	s.Expect(project.Tasks["t"].Commands).To(HaveExactElements(
+		"for f in *; do echo $f ${f%.txt} $1; done",
+		"echo $(date) $$ $PWD ${MUX_TEST_HOST:-other} ~",
+		"serve --port 8080",
+	))
+}
+
+func (s *InterpolateTestSuite) TestProjectVarsTakePrecedence() {
+	project := s.decodeProject(`
+projects:
+  - name: p
+    vars:
+      MUX_TEST_HOST: example.com
+      PORT: ${MUX_TEST_PORT:-8080}
+    windows:
+      - name: $MUX_TEST_HOST:$PORT`)
+	s.Expect(project.Windows[0].Name).To(Equal("example.com:8080"))
+}
+
+func (s *InterpolateTestSuite) TestBuiltins() {
+	project := s.decodeProject(`
+projects:
+  - name: api
+    working_dir: /src/api
+    windows:
+      - name: "{{project.name}}"
+    tasks:
+      test:
+        commands:
+          - echo {{ task.name }} in {{project.dir}}`)
+	s.Expect(project.Windows[0].Name).To(Equal("api"))
+	s.Expect(project.Tasks["test"].Commands).To(HaveExactElements("echo test in /src/api"))
+}
+
+func (s *InterpolateTestSuite) TestCommandSubstitutionRequiresOptIn() {
+	project := s.decodeProject(`
+projects:
+  - name: p
+    command_substitution: true
+    working_dir: /src/$(echo p)`)
+	s.Expect(project.WorkingDirectory).To(Equal("/src/p"))
+}
+
+func (s *InterpolateTestSuite) TestCommandsRunOnlyWhenResolved() {
+	c, err := Decode(strings.NewReader(`
+projects:
+  - name: p
+    command_substitution: true
+    working_dir: /src/$(false)`))
+	s.Expect(err).ToNot(HaveOccurred())
+	_, err = c.Projects[0].Resolve()
+	s.Expect(err).To(MatchError(ContainSubstring("working_dir")))
+}
+
+func (s *InterpolateTestSuite) TestErrorsReportFieldPath() {
+	_, err := s.resolveProject(`
+projects:
+  - name: p
+    windows:
+      - name: editor
+      - name: server:${MUX_TEST_PORT:?must be configured}`)
+	s.Expect(err).To(MatchError(And(
+		ContainSubstring("windows[1].name"),
+		ContainSubstring("must be configured"),
+	)))
+}
+
+func (s *InterpolateTestSuite) TestTaskNameOutsideTaskIsAnError() {
+	_, err := s.resolveProject(`
+projects:
+  - name: p
+    windows:
+      - name: "{{task.name}}"`)
+	s.Expect(err).To(MatchError(ContainSubstring("windows[0].name")))
+}
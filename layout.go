@@ -0,0 +1,128 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+)
+
+// splitFlag returns the flag of split-window, join-pane, and move-pane placing
+// a pane after another in the window's layout.
+func (w Window) splitFlag() (string, error) {
+	switch w.Layout {
+	case "horizontal", "":
+		return "-h", nil
+	case "vertical":
+		return "-v", nil
+	}
+	return "", errors.New("Invalid window layout")
+}
+
+// comparePositions orders panes left to right in a horizontal layout, or top to
+// bottom in a vertical layout.
+func comparePositions(horizontal bool) func(a, b TmuxPane) int {
+	return func(a, b TmuxPane) int {
+		if horizontal {
+			return cmp.Or(cmp.Compare(a.Layout.Left, b.Layout.Left), cmp.Compare(a.Layout.Top, b.Layout.Top))
+		}
+		return cmp.Or(cmp.Compare(a.Layout.Top, b.Layout.Top), cmp.Compare(a.Layout.Left, b.Layout.Left))
+	}
+}
+
+// joinMovedPanes moves the panes of the window's tasks from other windows of
+// the session, e.g., when a task was moved to another window in the
+// configuration, keeping the task running. A pane is left in place if the task
+// is also configured in its window, or it's the last pane of its window.
+func (w *TmuxWindow) joinMovedPanes(
+	ctx context.Context,
+	p Project,
+	configured Window,
+	panes TmuxPanes,
+) (bool, error) {
+	var missing []TaskId
+	for _, taskId := range configured.Panes {
+		if panes.FindByTitle(taskId) == nil {
+			missing = append(missing, taskId)
+		}
+	}
+	if len(missing) == 0 {
+		return false, nil
+	}
+	flag, err := configured.splitFlag()
+	if err != nil {
+		return false, err
+	}
+	sessionPanes, err := w.query(ctx, tmuxFormat{
+		field("pane_id"),
+		field("pane_title"),
+		field("window_id"),
+		encodedField("window_name"),
+		field("window_panes"),
+	}, "list-panes", "-s", "-t", w.Id)
+	if err != nil {
+		return false, err
+	}
+	remaining := make(map[string]int)
+	joins := w.Batch()
+	for _, fields := range sessionPanes {
+		paneId, taskId, windowId, windowName := fields[0], fields[1], fields[2], fields[3]
+		if windowId == w.Id || !slices.Contains(missing, taskId) {
+			continue
+		}
+		if _, ok := remaining[windowId]; !ok {
+			remaining[windowId], _ = strconv.Atoi(fields[4])
+		}
+		configuredInWindow := slices.ContainsFunc(p.Windows, func(w Window) bool {
+			return w.Name == windowName && slices.Contains(w.Panes, taskId)
+		})
+		if remaining[windowId] <= 1 || configuredInWindow {
+			continue
+		}
+		joins.Add("join-pane", "-d", flag, "-s", paneId, "-t", w.Id)
+		remaining[windowId]--
+		missing = slices.DeleteFunc(missing, func(id TaskId) bool { return id == taskId })
+	}
+	return joins.Len() > 0, joins.Run(ctx)
+}
+
+// arrangePanes swaps the panes of the window's tasks into the configured
+// order, e.g., when the order was changed in the configuration, or a pane was
+// dragged to another place. Only the order of the panes is compared, so the
+// user's resized or stacked panes are kept, as swapping panes keeps the layout.
+// Zoomed windows are left alone, as swapping panes would unzoom them. Other
+// panes in the window are left alone.
+func (w *TmuxWindow) arrangePanes(ctx context.Context, configured Window, panes TmuxPanes) error {
+	flag, err := configured.splitFlag()
+	if err != nil {
+		return err
+	}
+	horizontal := flag == "-h"
+	var taskPanes TmuxPanes
+	var order []TaskId
+	for _, taskId := range configured.Panes {
+		if pane := panes.FindByTitle(taskId); pane != nil {
+			taskPanes = append(taskPanes, *pane)
+			order = append(order, taskId)
+		}
+	}
+	slices.SortStableFunc(taskPanes, comparePositions(horizontal))
+	swaps := w.Batch()
+	for i, taskId := range order {
+		j := i + slices.IndexFunc(taskPanes[i:], func(p TmuxPane) bool { return p.Title == taskId })
+		if j == i {
+			continue
+		}
+		swaps.Add("swap-pane", "-d", "-s", taskPanes[j].Id, "-t", taskPanes[i].Id)
+		taskPanes[i], taskPanes[j] = taskPanes[j], taskPanes[i]
+	}
+	if swaps.Len() == 0 {
+		return nil
+	}
+	zoomed, err := w.displayVariable(ctx, "window_zoomed_flag")
+	if err != nil || zoomed == "1" {
+		return err
+	}
+	return swaps.Run(ctx)
+}
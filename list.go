@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// WriteProjectList writes the configured projects, marking running projects
+// with a "*". Sessions started from a project, e.g., in a git worktree, are
+// listed below the project.
+func WriteProjectList(w io.Writer, config MuxifyConfiguration, sessions TmuxSessions) error {
+	for _, project := range config.Projects {
+		marker := " "
+		if _, running := sessions.FindByName(project.Name); running {
+			marker = "*"
+		}
+		if _, err := fmt.Fprintf(w, "%s %s\n", marker, project.Name); err != nil {
+			return err
+		}
+		for _, session := range sessions {
+			if parent, _, ok := config.sessionProjectName(session.Name); ok && parent == project.Name {
+				if _, err := fmt.Fprintf(w, "  * %s\n", session.Name); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrAlreadyReconciling is returned when another muxify process reconciles the
+// same session for longer than the lock timeout.
+var ErrAlreadyReconciling = errors.New("already reconciling")
+
+const (
+	defaultLockTimeout = 30 * time.Second
+	lockPollInterval   = 50 * time.Millisecond
+)
+
+func (s TmuxServer) lockDir() string {
+	if s.LockDir != "" {
+		return s.LockDir
+	}
+	if dir, ok := os.LookupEnv("XDG_RUNTIME_DIR"); ok && dir != "" {
+		return dir
+	}
+	return os.TempDir()
+}
+
+func (s TmuxServer) lockTimeout() time.Duration {
+	if s.LockTimeout != 0 {
+		return s.LockTimeout
+	}
+	return defaultLockTimeout
+}
+
+// lockPath returns the path of the lock file of a session, keyed by the server
+// socket and the session name.
+func (s TmuxServer) lockPath(sessionName string) string {
+	socket := "default"
+	if s.SocketPath != "" {
+		socket = "path:" + s.SocketPath
+	} else if s.SocketName != "" {
+		socket = "name:" + s.SocketName
+	}
+	key := sha256.Sum256([]byte(socket + "\x00" + tmuxSessionName(sessionName)))
+	return filepath.Join(s.lockDir(), fmt.Sprintf("muxify-%x.lock", key[:12]))
+}
+
+// LockSession takes an exclusive lock for reconciling the session, waiting while
+// another process holds it, e.g., when muxify runs from a hook and a key binding
+// at the same time. The returned function releases the lock.
+func (s TmuxServer) LockSession(
+	ctx context.Context,
+	sessionName string,
+) (unlock func() error, err error) {
+	file, err := os.OpenFile(s.lockPath(sessionName), os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(s.lockTimeout())
+	for waiting := false; ; waiting = true {
+		locked, err := tryLock(file)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		if locked {
+			// Closing the file releases the lock
+			return file.Close, nil
+		}
+		if time.Now().After(deadline) {
+			file.Close()
+			return nil, fmt.Errorf(
+				"Session %q is %w by another muxify process", sessionName, ErrAlreadyReconciling)
+		}
+		if !waiting {
+			slog.Info("Waiting for another muxify process reconciling the session", "session", sessionName)
+		}
+		select {
+		case <-ctx.Done():
+			file.Close()
+			return nil, context.Cause(ctx)
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
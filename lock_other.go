@@ -0,0 +1,11 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// tryLock doesn't lock on platforms without flock, where tmux doesn't run
+// natively anyway.
+func tryLock(file *os.File) (bool, error) {
+	return true, nil
+}
@@ -0,0 +1,47 @@
+package main_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	. "github.com/stroiman/muxify"
+)
+
+func TestLockSession(t *testing.T) {
+	ctx := context.Background()
+	server := TmuxServer{SocketName: "muxify-test", LockDir: t.TempDir(), LockTimeout: 100 * time.Millisecond}
+	unlock, err := server.LockSession(ctx, "Project 1")
+	assert.NoError(t, err)
+
+	_, err = server.LockSession(ctx, "Project 1")
+	assert.ErrorIs(t, err, ErrAlreadyReconciling)
+	unlockOther, err := server.LockSession(ctx, "Project 2")
+	assert.NoError(t, err, "Other sessions aren't locked")
+	assert.NoError(t, unlockOther())
+	otherServer := server
+	otherServer.SocketName = "muxify-other"
+	unlockOther, err = otherServer.LockSession(ctx, "Project 1")
+	assert.NoError(t, err, "Sessions on other servers aren't locked")
+	assert.NoError(t, unlockOther())
+
+	assert.NoError(t, unlock())
+	unlock, err = server.LockSession(ctx, "Project 1")
+	assert.NoError(t, err)
+	assert.NoError(t, unlock())
+}
+
+func TestLockSessionWaitsForRelease(t *testing.T) {
+	ctx := context.Background()
+	server := TmuxServer{SocketName: "muxify-test", LockDir: t.TempDir()}
+	unlock, err := server.LockSession(ctx, "Project 1")
+	assert.NoError(t, err)
+	time.AfterFunc(100*time.Millisecond, func() { unlock() })
+
+	start := time.Now()
+	unlock, err = server.LockSession(ctx, "Project 1")
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+	assert.NoError(t, unlock())
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// managedOptionsOption is the user option recording the names of the options
+// set by muxify on a session, window, or pane, allowing options removed from
+// the configuration to be unset.
+const managedOptionsOption = "@muxify_options"
+
+// Option scopes, i.e., the set-option flag for options of a target
+const (
+	sessionScope = ""
+	windowScope  = "-w"
+	paneScope    = "-p"
+)
+
+func (t TmuxTarget) optionArgs(command string, scope string, extra ...string) []string {
+	args := []string{command}
+	if scope != sessionScope {
+		args = append(args, scope)
+	}
+	return append(append(args, "-t", t.Id), extra...)
+}
+
+func (t TmuxTarget) setOption(ctx context.Context, scope string, name string, value string) error {
+	return t.Command(ctx, t.optionArgs("set-option", scope, name, value)...).Run()
+}
+
+func (t TmuxTarget) unsetOption(ctx context.Context, scope string, name string) error {
+	return t.Command(ctx, t.optionArgs("set-option", scope, "-u", name)...).Run()
+}
+
+// showOption returns the value of an option set on the target itself, or an
+// empty string if the option isn't set.
+func (t TmuxTarget) showOption(ctx context.Context, scope string, name string) (string, error) {
+	output, err := t.Command(ctx, t.optionArgs("show-options", scope, "-q", "-v", name)...).Output()
+	return sanitizeOutput(output), err
+}
+
+// reconcileOptions sets the configured options on the target, and unsets
+// options previously set by muxify that are no longer configured.
+func (t TmuxTarget) reconcileOptions(
+	ctx context.Context,
+	scope string,
+	options map[string]string,
+) error {
+	previous, err := t.showOption(ctx, scope, managedOptionsOption)
+	if err != nil {
+		return err
+	}
+	for _, name := range strings.Fields(previous) {
+		if _, ok := options[name]; !ok {
+			if err = t.unsetOption(ctx, scope, name); err != nil {
+				return err
+			}
+		}
+	}
+	names := make([]string, 0, len(options))
+	for name, value := range options {
+		if err = t.setOption(ctx, scope, name, value); err != nil {
+			return err
+		}
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		if previous == "" {
+			return nil
+		}
+		return t.unsetOption(ctx, scope, managedOptionsOption)
+	}
+	slices.Sort(names)
+	return t.setOption(ctx, scope, managedOptionsOption, strings.Join(names, " "))
+}
+
+// paneOptions returns the options of the task's pane, i.e., the configured
+// options, and remain-on-exit if the task keeps its pane open, or the
+// supervisor restarts it. Managing remain-on-exit as an option unsets it when
+// keep_open is removed.
+func (t Task) paneOptions() map[string]string {
+	if !t.KeepOpen && t.Restart == "" {
+		return t.Options
+	}
+	options := maps.Clone(t.Options)
+	if options == nil {
+		options = make(map[string]string)
+	}
+	if _, ok := options["remain-on-exit"]; !ok {
+		options["remain-on-exit"] = "on"
+	}
+	return options
+}
+
+// reconcileOptions applies the options configured on the project, its windows,
+// and tasks, to the session, windows, and panes.
+func (p Project) reconcileOptions(
+	ctx context.Context,
+	session TmuxSession,
+	windowMap TmuxWindowMap,
+) error {
+	if err := session.reconcileOptions(ctx, sessionScope, p.Options); err != nil {
+		return err
+	}
+	for _, w := range p.Windows {
+		tmuxWindow := windowMap[w.id]
+		if err := tmuxWindow.reconcileOptions(ctx, windowScope, w.Options); err != nil {
+			return err
+		}
+		panes, err := tmuxWindow.GetPanes(ctx)
+		if err != nil {
+			return err
+		}
+		for _, taskId := range w.Panes {
+			if pane := panes.FindByTitle(taskId); pane != nil {
+				if err = pane.reconcileOptions(ctx, paneScope, p.Tasks[taskId].paneOptions()); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// WritePlan writes the windows and panes of the project as they will be
+// started, with the working directory each resolves to.
+func WritePlan(w io.Writer, project Project) error {
+	project, err := project.Plan()
+	if err != nil {
+		return err
+	}
+	if _, err = fmt.Fprintf(w, "%s (%s)\n", project.Name, describeDir(project.WorkingDirectory)); err != nil {
+		return err
+	}
+	for _, window := range project.Windows {
+		_, err = fmt.Fprintf(w, "  %s (%s)\n", window.Name, describeDir(project.WindowDir(window)))
+		if err != nil {
+			return err
+		}
+		for _, taskId := range window.Panes {
+			_, err = fmt.Fprintf(w, "    %s (%s)\n", taskId, describeDir(project.PaneDir(window, taskId)))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return project.ValidateDirs()
+}
+
+func describeDir(dir string) string {
+	if dir == "" {
+		return "current dir"
+	}
+	return dir
+}
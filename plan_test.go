@@ -0,0 +1,78 @@
+package main_test
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/suite"
+
+	. "github.com/stroiman/muxify"
+)
+
+type PlanTestSuite struct {
+	GomegaSuite
+	dir     string
+	project Project
+}
+
+func TestPlan(t *testing.T) {
+	suite.Run(t, new(PlanTestSuite))
+}
+
+func (s *PlanTestSuite) SetupTest() {
+	s.GomegaSuite.SetupTest()
+	s.dir = s.T().TempDir()
+	s.Expect(os.MkdirAll(path.Join(s.dir, "web", "client"), 0700)).To(Succeed())
+	s.Expect(os.MkdirAll(path.Join(s.dir, "other"), 0700)).To(Succeed())
+	config, err := Decode(strings.NewReader(`
+projects:
+  - name: project
+    working_dir: ` + s.dir + `
+    windows:
+      - name: Editor
+        panes: [editor]
+      - name: Web
+        working_dir: web
+        panes: [server, client, other]
+    tasks:
+      editor:
+      server:
+      client:
+        working_dir: client
+      other:
+        working_dir: ` + path.Join(s.dir, "other") + `
+`))
+	s.Expect(err).ToNot(HaveOccurred())
+	s.project = config.Projects[0]
+}
+
+func (s *PlanTestSuite) TestDirectoryPrecedence() {
+	editor, web := s.project.Windows[0], s.project.Windows[1]
+	s.Expect(s.project.PaneDir(editor, "editor")).To(Equal(s.dir))
+	s.Expect(s.project.WindowDir(web)).To(Equal(path.Join(s.dir, "web")))
+	s.Expect(s.project.PaneDir(web, "server")).To(Equal(path.Join(s.dir, "web")))
+	s.Expect(s.project.PaneDir(web, "client")).To(Equal(path.Join(s.dir, "web", "client")))
+	s.Expect(s.project.PaneDir(web, "other")).To(Equal(path.Join(s.dir, "other")))
+}
+
+func (s *PlanTestSuite) TestWritePlan() {
+	var b strings.Builder
+	s.Expect(WritePlan(&b, s.project)).To(Succeed())
+	s.Expect(b.String()).To(Equal("project (" + s.dir + ")\n" +
+		"  Editor (" + s.dir + ")\n" +
+		"    editor (" + s.dir + ")\n" +
+		"  Web (" + s.dir + "/web)\n" +
+		"    server (" + s.dir + "/web)\n" +
+		"    client (" + s.dir + "/web/client)\n" +
+		"    other (" + s.dir + "/other)\n"))
+}
+
+func (s *PlanTestSuite) TestMissingDirectory() {
+	s.Expect(os.Remove(path.Join(s.dir, "web", "client"))).To(Succeed())
+	s.Expect(s.project.ValidateDirs()).To(MatchError(
+		`Window "Web", task "client": Working directory ` + s.dir + `/web/client does not exist`,
+	))
+}
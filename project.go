@@ -1,8 +1,14 @@
 package main
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"log/slog"
+	"os"
 	"path"
+	"regexp"
+	"slices"
 
 	"github.com/google/uuid"
 )
@@ -13,49 +19,150 @@ type Commands = []Command
 
 type Task struct {
 	WorkingDirectory string `yaml:"working_dir,omitempty"`
-	Commands         Commands
+	// Commands are typed into the pane's shell, or into the program
+	Commands Commands
+	// Exec is a program run as the pane's process, rather than typing commands
+	// into a shell. The pane closes when the program exits, unless KeepOpen is
+	// set.
+	Exec string `yaml:"exec,omitempty"`
+	// Shell is the shell started in the pane, instead of the default shell. With
+	// Exec, or a restart policy, the shell runs the program or the commands.
+	Shell string `yaml:"shell,omitempty"`
+	// KeepOpen keeps the pane open when its program exits, showing the exit
+	// status
+	KeepOpen bool `yaml:"keep_open,omitempty"`
+	// Prompt is a regular expression matching the last line of the pane when
+	// its shell is ready for input. Without a prompt, commands are typed once
+	// the pane's output has been unchanged for a moment.
+	Prompt string `yaml:"prompt,omitempty"`
+	// promptPattern is the compiled prompt, set by compilePrompt
+	promptPattern *regexp.Regexp
+	When          *Condition `yaml:"when,omitempty"`
+	// Focus selects the task's pane in its window after starting
+	Focus bool `yaml:"focus,omitempty"`
+	// Zoom zooms the task's pane when it is focused
+	Zoom bool `yaml:"zoom,omitempty"`
+	// Options are tmux pane options set on the task's pane
+	Options map[string]string `yaml:"options,omitempty"`
+	// Restart is the policy for restarting the task when its process exits
+	Restart RestartPolicy `yaml:"restart,omitempty"`
 }
 
 type Project struct {
 	Name             string
 	WorkingDirectory string `yaml:"working_dir,omitempty"`
-	Windows          []Window
-	Tasks            map[string]Task
+	// Extends is the name of a template to add windows and tasks from
+	Extends string            `yaml:"extends,omitempty"`
+	Params  map[string]string `yaml:"params,omitempty"`
+	// Vars are variables available for interpolation, e.g. `${PORT}`
+	Vars map[string]string `yaml:"vars,omitempty"`
+	// CommandSubstitution enables `$(command)` in configuration values
+	CommandSubstitution bool `yaml:"command_substitution,omitempty"`
+	Windows             []Window
+	Tasks               map[string]Task
+	// Options are tmux options set on the session
+	Options map[string]string `yaml:"options,omitempty"`
+	// Bindings map tmux keys, pressed after the prefix, to muxify commands run
+	// for the session, e.g., `restart server`
+	Bindings map[string]string `yaml:"bindings,omitempty"`
+	// Profile is the profile selected when starting the project, used to
+	// evaluate `when` conditions
+	Profile string `yaml:"-"`
+	// Saved is the session state saved with `muxify save`, used to restore the
+	// scrollback of recreated panes
+	Saved *SavedSession `yaml:"-"`
+	// FocusTask is the task to focus after starting, overriding the focus in
+	// the configuration
+	FocusTask TaskId `yaml:"-"`
+	// configDir is the directory of the configuration file defining the
+	// project, which a relative working dir is relative to
+	configDir string
+	// worktree is the branch of the git worktree to start the project in, set
+	// by ForWorktree
+	worktree string
 }
 
-func (p Project) FirstTask() (t Task, ok bool) {
-	if len(p.Windows) == 0 {
-		return
+// WindowDir returns the working dir of the window. A relative window dir is
+// relative to the project dir.
+func (p Project) WindowDir(w Window) string {
+	return resolveDir(p.WorkingDirectory, w.WorkingDirectory)
+}
+
+// PaneDir returns the working dir of the pane running the task in the window.
+// A relative task dir is relative to the window dir.
+func (p Project) PaneDir(w Window, taskId TaskId) string {
+	return resolveDir(p.WindowDir(w), p.Tasks[taskId].WorkingDirectory)
+}
+
+// InitialWindowDir returns the working dir to create the window in, which is
+// the working dir of the first pane.
+func (p Project) InitialWindowDir(w Window) string {
+	if len(w.Panes) == 0 {
+		return p.WindowDir(w)
 	}
-	return p.FirstWindowTask(p.Windows[0])
+	return p.PaneDir(w, w.Panes[0])
 }
 
-func (p Project) WindowDir(w Window) string {
-	dir := p.WorkingDirectory
-	if task, ok := p.FirstWindowTask(w); ok && dir != "" {
-		return p.TaskDir(task)
+// windowSpec returns how to create the window, with its first pane running
+// the first task's program or shell.
+func (p Project) windowSpec(w Window) WindowSpec {
+	spec := WindowSpec{Name: w.Name, WorkingDir: p.InitialWindowDir(w)}
+	if len(w.Panes) > 0 {
+		spec.Command = p.initialCommand(w, w.Panes[0])
+		spec.RemainOnExit = spec.Command != "" || p.Tasks[w.Panes[0]].KeepOpen
 	}
-	return dir
+	return spec
 }
 
-func (p Project) TaskDir(t Task) string {
-	dir := p.WorkingDirectory
-	if t.WorkingDirectory != "" {
-		return path.Join(dir, t.WorkingDirectory)
+// initialCommand returns the command the pane of the task is created with,
+// i.e., the task's program or shell, unless the pane is started by the
+// supervisor, or replays the saved history in a shell.
+func (p Project) initialCommand(w Window, taskId TaskId) string {
+	task := p.Tasks[taskId]
+	if task.Restart != "" {
+		return ""
 	}
-	return dir
+	if _, ok := p.Saved.findPane(w.Name, taskId); ok && task.Exec == "" {
+		return ""
+	}
+	return task.paneCommand()
 }
 
-func (p Project) FirstWindowTask(w Window) (t Task, ok bool) {
-	if len(w.Panes) == 0 {
-		return
+// resolveDir resolves dir relative to base, unless dir is absolute.
+func resolveDir(base string, dir string) string {
+	if path.IsAbs(dir) {
+		return dir
 	}
-	taskId := w.Panes[0]
-	if p.Tasks == nil {
-		return
+	return path.Join(base, dir)
+}
+
+// ValidateDirs verifies that the working directories of all windows and panes
+// exist.
+func (p Project) ValidateDirs() error {
+	check := func(dir string, description string) error {
+		if dir == "" {
+			return nil
+		}
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			return fmt.Errorf("%s: Working directory %s does not exist", description, dir)
+		}
+		return nil
 	}
-	t, ok = p.Tasks[taskId]
-	return
+	if err := check(p.WorkingDirectory, fmt.Sprintf("Project %q", p.Name)); err != nil {
+		return err
+	}
+	for _, w := range p.Windows {
+		if err := check(p.WindowDir(w), fmt.Sprintf("Window %q", w.Name)); err != nil {
+			return err
+		}
+		for _, taskId := range w.Panes {
+			description := fmt.Sprintf("Window %q, task %q", w.Name, taskId)
+			if err := check(p.PaneDir(w, taskId), description); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
 type WindowId = uuid.UUID
@@ -63,14 +170,93 @@ type WindowId = uuid.UUID
 type TaskId = string //
 
 type Window struct {
-	id     WindowId
-	Name   string
-	Panes  []TaskId
-	Layout string
+	id WindowId
+	// StableId identifies the window in the running session, so renaming the
+	// window in the configuration renames the tmux window, rather than creating
+	// a new window.
+	StableId         string `yaml:"id,omitempty"`
+	Name             string
+	WorkingDirectory string `yaml:"working_dir,omitempty"`
+	Panes            []TaskId
+	Layout           string
+	ForEach          *ForEach `yaml:"for_each,omitempty"`
+	// Prune removes windows expanded from ForEach that no longer match
+	Prune bool       `yaml:"prune,omitempty"`
+	When  *Condition `yaml:"when,omitempty"`
+	// Focus selects the window after starting, instead of the first window
+	Focus bool `yaml:"focus,omitempty"`
+	// Options are tmux window options set on the window
+	Options map[string]string `yaml:"options,omitempty"`
+	// expandedFrom is the key of the window definition this window was
+	// expanded from using ForEach
+	expandedFrom string
 }
 
 var emptyUUID = uuid.UUID{}
 
+// windowIdOption is the tmux window option recording the stable id of the
+// configured window
+const windowIdOption = "@muxify_id"
+
+// findIn returns the running window of the configured window. A window with a
+// stable id is found by the id, falling back to the name for a window started
+// before the id was configured.
+func (w Window) findIn(tmuxWindows TmuxWindows) (TmuxWindow, bool) {
+	for _, tmuxWindow := range tmuxWindows {
+		if w.StableId != "" && tmuxWindow.StableId == w.StableId {
+			return tmuxWindow, true
+		}
+	}
+	for _, tmuxWindow := range tmuxWindows {
+		if tmuxWindow.Name == w.Name && (w.StableId == "" || tmuxWindow.StableId == "") {
+			return tmuxWindow, true
+		}
+	}
+	return TmuxWindow{}, false
+}
+
+// validateStableIds verifies that no two windows have the same stable id
+func (p Project) validateStableIds() error {
+	ids := make(map[string]bool)
+	for _, w := range p.Windows {
+		if w.StableId == "" {
+			continue
+		}
+		if ids[w.StableId] {
+			return fmt.Errorf("Window %q: Duplicate window id %q", w.Name, w.StableId)
+		}
+		ids[w.StableId] = true
+	}
+	return nil
+}
+
+// compilePrompt returns the task with its prompt compiled, failing if the
+// prompt isn't a valid regular expression.
+func (t Task) compilePrompt() (Task, error) {
+	t.promptPattern = nil
+	if t.Prompt == "" {
+		return t, nil
+	}
+	var err error
+	if t.promptPattern, err = regexp.Compile(t.Prompt); err != nil {
+		return t, fmt.Errorf("Invalid prompt: %w", err)
+	}
+	return t, nil
+}
+
+// compilePrompts returns the project with the prompts of its tasks compiled
+func (p Project) compilePrompts() (Project, error) {
+	tasks := make(map[string]Task, len(p.Tasks))
+	for id, task := range p.Tasks {
+		var err error
+		if tasks[id], err = task.compilePrompt(); err != nil {
+			return p, fmt.Errorf("Task %q: %w", id, err)
+		}
+	}
+	p.Tasks = tasks
+	return p, nil
+}
+
 func (w *Window) EnsureValid() *Window {
 	if w.id == emptyUUID {
 		w.id = uuid.New()
@@ -87,62 +273,129 @@ func NewWindow(name string, panes ...TaskId) Window {
 }
 
 func startSessionAndSetFirstWindowName(
+	ctx context.Context,
 	server TmuxServer,
 	project Project,
 ) (session TmuxSession, err error) {
-	if project.WorkingDirectory == "" {
-		session, err = server.StartSessionByName(project.Name)
-	} else {
-		task, _ := project.FirstTask()
-		session, err = server.StartSessionByNameInDir(project.Name, project.TaskDir(task))
+	window := WindowSpec{WorkingDir: project.WorkingDirectory}
+	if len(project.Windows) > 0 {
+		window = project.windowSpec(project.Windows[0])
 	}
-	if err == nil && len(project.Windows) > 0 {
-		err = server.RenameWindow(session.Id, project.Windows[0].Name)
-	}
-	return
+	return server.StartSessionWithWindow(ctx, project.Name, window)
 }
 
+// ensureWindowHasPanes creates the missing panes of the window, and starts
+// their tasks, and arranges the panes in the configured order. Panes of tasks
+// moved from other windows are moved along with their running processes. All
+// panes are split in one tmux invocation, and their tasks are started in
+// another.
 func ensureWindowHasPanes(
+	ctx context.Context,
 	window *TmuxWindow,
 	project Project,
 	configuredWindow Window,
+	created bool,
+	input *paneInput,
+	steps *progress,
 ) error {
 	if window == nil {
 		panic("Window must not be nil")
 	}
-	tmuxPanes, err := window.GetPanes()
+	tmuxPanes, err := window.GetPanes(ctx)
 	if err != nil {
 		return err
 	}
-	for i, pane := range configuredWindow.Panes {
-		var existingPane = tmuxPanes.FindByTitle(pane)
-		if existingPane == nil {
-			task := project.FindTaskById(pane)
-			var tmuxPane TmuxPane
-
-			if i == 0 {
-				if tmuxPane, err = window.GetFirstPane(); err == nil {
-					tmuxPane, err = tmuxPane.Rename(pane)
-				}
-			} else {
-				workingDir := project.TaskDir(*task)
-				if configuredWindow.Layout == "horizontal" || configuredWindow.Layout == "" {
-					tmuxPane, err = window.SplitHorizontal(pane, workingDir)
-				} else if configuredWindow.Layout == "vertical" {
-					tmuxPane, err = window.SplitVertical(pane, workingDir)
-				} else {
-					err = errors.New("Invalid window layout")
-				}
-			}
-			if err != nil {
-				return err
-			}
-			for _, command := range task.Commands {
-				if err == nil {
-					err = tmuxPane.RunShellCommand(command)
-				}
-			}
+	joined, err := window.joinMovedPanes(ctx, project, configuredWindow, tmuxPanes)
+	if err == nil && joined {
+		tmuxPanes, err = window.GetPanes(ctx)
+	}
+	if err != nil {
+		return err
+	}
+	// The first pane of a created window runs the window's first task, which
+	// was moved into the window instead
+	if created && joined && !slices.Contains(configuredWindow.Panes, tmuxPanes[0].Title) &&
+		tmuxPanes.FindByTitle(configuredWindow.Panes[0]) != nil {
+		if err = window.Command(ctx, "kill-pane", "-t", tmuxPanes[0].Id).Run(); err != nil {
+			return err
 		}
+		tmuxPanes = tmuxPanes[1:]
+	}
+	var taskIds []TaskId
+	var panes TmuxPanes
+	// launched is whether each pane was created running the task's program or
+	// shell
+	var launched []bool
+	splits := window.Batch()
+	for i, taskId := range configuredWindow.Panes {
+		if tmuxPanes.FindByTitle(taskId) != nil {
+			continue
+		}
+		taskIds = append(taskIds, taskId)
+		// The first pane of the window is used for the first task, unless it runs
+		// another task, e.g., when the first task is added to a running window
+		if i == 0 && !slices.Contains(configuredWindow.Panes, tmuxPanes[0].Title) {
+			panes = append(panes, tmuxPanes[0])
+			launched = append(launched, created)
+			continue
+		}
+		direction, err := configuredWindow.splitFlag()
+		if err != nil {
+			return err
+		}
+		command := project.initialCommand(configuredWindow, taskId)
+		splits.Add(window.splitArgs(direction, project.PaneDir(configuredWindow, taskId), command)...)
+		if command != "" || project.Tasks[taskId].KeepOpen {
+			splits.Add(remainOnExitArgs...)
+		}
+		launched = append(launched, true)
+	}
+	if len(taskIds) == 0 {
+		steps.start("arranging panes in window %q", configuredWindow.Name)
+		return window.arrangePanes(ctx, configuredWindow, tmuxPanes)
+	}
+	steps.start("starting tasks in window %q", configuredWindow.Name)
+	split, err := window.createPanes(ctx, splits)
+	if err != nil {
+		return err
+	}
+	panes = append(panes, split...)
+	tasks := window.Batch()
+	for i, taskId := range taskIds {
+		pane := panes[i]
+		tasks.Add(pane.renameArgs(taskId)...)
+		task := project.FindTaskById(taskId)
+		if task == nil {
+			continue
+		}
+		tasks.Add(pane.optionArgs("set-option", paneScope, commandsOption, task.signature())...)
+		dir := project.PaneDir(configuredWindow, taskId)
+		if task.Restart != "" {
+			pane.startSupervised(tasks, *task, dir)
+			continue
+		}
+		var saved *SavedPane
+		if savedPane, ok := project.Saved.findPane(configuredWindow.Name, taskId); ok {
+			saved = &savedPane
+		}
+		if err = pane.startTask(ctx, tasks, input, *task, dir, saved, launched[i]); err != nil {
+			return err
+		}
+	}
+	if err = tasks.Run(ctx); err != nil {
+		return err
+	}
+	steps.start("arranging panes in window %q", configuredWindow.Name)
+	if tmuxPanes, err = window.GetPanes(ctx); err != nil {
+		return err
+	}
+	if err = window.arrangePanes(ctx, configuredWindow, tmuxPanes); err != nil {
+		return err
+	}
+	// tmux exits on an empty layout
+	if saved, ok := project.Saved.findWindow(configuredWindow.Name); ok && saved.Layout != "" &&
+		len(saved.Panes) == len(configuredWindow.Panes) {
+		return window.SelectLayout(ctx, saved.Layout)
 	}
 	return nil
 }
@@ -169,31 +422,133 @@ func (p Project) Validate() error {
 // that _may_ or _may not_ be properly configured
 type TmuxWindowMap = map[WindowId]*TmuxWindow
 
-func (p Project) ensureSession(server TmuxServer) (session TmuxSession, err error) {
+// ensureSession returns the project's session, starting it if it isn't
+// running.
+func (p Project) ensureSession(
+	ctx context.Context,
+	server TmuxServer,
+) (session TmuxSession, started bool, err error) {
 	var ok bool
-	sessions, err := server.GetRunningSessions()
+	sessions, err := server.GetRunningSessions(ctx)
 	session, ok = TmuxSessions(sessions).FindByName(p.Name)
 	if err == nil && !ok {
+		started = true
 		// Set first window name - a session always has a window, and if the name
 		// doesn't match a configured window, the tool will leave it be, as if it
 		// was created by the user.
-		session, err = startSessionAndSetFirstWindowName(server, p)
+		session, err = startSessionAndSetFirstWindowName(ctx, server, p)
 	}
 	return
 }
 
-func (p Project) EnsureStarted(server TmuxServer) (session TmuxSession, err error) {
-	session, err = p.ensureSession(server)
+// createWindows creates the missing window at index i in the configuration
+// at the target, along with the missing windows directly following it, in a
+// single tmux invocation.
+func (p Project) createWindows(
+	ctx context.Context,
+	server TmuxServer,
+	target WindowTarget,
+	i int,
+	windowMap TmuxWindowMap,
+	created map[WindowId]bool,
+) error {
+	var missing []Window
+	for _, w := range p.Windows[i:] {
+		if windowMap[w.id] != nil {
+			break
+		}
+		missing = append(missing, w)
+	}
+	specs := make([]WindowSpec, len(missing))
+	for j, w := range missing {
+		specs[j] = p.windowSpec(w)
+	}
+	tmuxWindows, err := server.CreateWindows(ctx, target, specs...)
+	if err != nil {
+		return err
+	}
+	for j, w := range missing {
+		windowMap[w.id] = tmuxWindows[j]
+		created[w.id] = true
+	}
+	return nil
+}
+
+// Plan returns the project as it will be started on this machine, i.e., without
+// windows and tasks excluded by conditions, and windows expanded from for_each.
+func (p Project) Plan() (Project, error) {
+	p, err := p.ApplyConditions()
+	if err == nil {
+		p, err = p.ExpandWindows()
+	}
+	return p, err
+}
+
+// progress is the step of starting a project in progress, which is reported
+// when the server's context is cancelled, e.g., by a timeout.
+type progress struct {
+	step string
+}
+
+func (p *progress) start(format string, args ...any) {
+	p.step = fmt.Sprintf(format, args...)
+	slog.Debug("Starting project", "step", p.step)
+}
+
+func (p Project) EnsureStarted(ctx context.Context, server TmuxServer) (session TmuxSession, err error) {
+	steps := &progress{}
+	defer func() {
+		if cause := context.Cause(ctx); err != nil && cause != nil {
+			err = fmt.Errorf("%w while %s", cause, steps.step)
+		}
+	}()
+	if p, err = p.ApplyConditions(); err != nil {
+		return
+	}
+	pruned := p.prunedForEachWindows()
+	if p, err = p.ExpandWindows(); err != nil {
+		return
+	}
+	if err = p.validateStableIds(); err != nil {
+		return
+	}
+	if p, err = p.compilePrompts(); err != nil {
+		return
+	}
+	if err = p.ValidateDirs(); err != nil {
+		return
+	}
+	steps.start("waiting for another muxify process starting the session")
+	unlock, err := server.LockSession(ctx, p.Name)
+	if err != nil {
+		return
+	}
+	defer func() { err = errors.Join(err, unlock()) }()
+	steps.start("starting the session")
+	var started bool
+	session, started, err = p.ensureSession(ctx, server)
+	if err != nil {
+		return
+	}
+	tmuxWindows, err := session.GetWindows(ctx)
+	if err == nil && len(tmuxWindows) == 0 {
+		err = fmt.Errorf("Session %q has no windows", session.Name)
+	}
 	if err != nil {
 		return
 	}
-	tmuxWindows, err := session.GetWindows()
 	windowMap := make(map[WindowId]*TmuxWindow)
+	created := make(map[WindowId]bool)
+	input := server.paneInput()
 	for _, window := range p.Windows {
-		if tmuxWindow, ok := tmuxWindows.FindByName(window.Name); ok {
+		if tmuxWindow, ok := window.findIn(tmuxWindows); ok {
 			windowMap[window.id] = &tmuxWindow
 		}
 	}
+	if started && len(p.Windows) > 0 {
+		// The session was started with the first window
+		created[p.Windows[0].id] = true
+	}
 
 	for i, configuredWindow := range p.Windows {
 		if err != nil {
@@ -213,19 +568,128 @@ func (p Project) EnsureStarted(server TmuxServer) (session TmuxSession, err erro
 
 		var existingWindow *TmuxWindow
 		if existingWindow = windowMap[configuredWindow.id]; existingWindow != nil {
-			err = server.MoveWindow(existingWindow, windowTarget)
+			if !created[configuredWindow.id] {
+				steps.start("moving window %q", configuredWindow.Name)
+				err = server.MoveWindow(ctx, existingWindow, windowTarget)
+			}
+			if err == nil && existingWindow.Name != configuredWindow.Name {
+				steps.start("renaming window %q to %q", existingWindow.Name, configuredWindow.Name)
+				err = server.RenameWindow(ctx, existingWindow.Id, configuredWindow.Name)
+				existingWindow.Name = configuredWindow.Name
+			}
 		} else {
-			existingWindow, err = server.CreateWindow(windowTarget, configuredWindow.Name, p.WindowDir(configuredWindow))
-			windowMap[configuredWindow.id] = existingWindow
+			steps.start("creating window %q", configuredWindow.Name)
+			err = p.createWindows(ctx, server, windowTarget, i, windowMap, created)
+			existingWindow = windowMap[configuredWindow.id]
+		}
+		if err == nil && existingWindow.ExpandedFrom != configuredWindow.expandedFrom {
+			err = existingWindow.SetOption(ctx, forEachWindowOption, configuredWindow.expandedFrom)
+		}
+		if err == nil && existingWindow.StableId != configuredWindow.StableId {
+			err = existingWindow.SetOption(ctx, windowIdOption, configuredWindow.StableId)
 		}
 		if err == nil {
-			err = ensureWindowHasPanes(existingWindow, p, configuredWindow)
+			err = ensureWindowHasPanes(
+				ctx, existingWindow, p, configuredWindow, created[configuredWindow.id], input, steps,
+			)
 		}
 	}
 
-	if len(p.Windows) > 0 {
-		targetTmuxWindow := windowMap[p.Windows[0].id]
-		targetTmuxWindow.Select()
+	if err == nil {
+		steps.start("waiting for the shells in new panes to be ready")
+		err = input.send(ctx)
+	}
+
+	if err == nil {
+		steps.start("removing windows of for_each matches")
+		err = p.pruneExpandedWindows(ctx, server, tmuxWindows, pruned)
+	}
+
+	if err == nil {
+		steps.start("setting tmux options")
+		err = p.reconcileOptions(ctx, session, windowMap)
+	}
+	if err == nil {
+		steps.start("binding keys")
+		err = p.reconcileBindings(ctx, server, session)
+	}
+	if err == nil {
+		steps.start("setting up restarting tasks")
+		err = p.reconcileSupervisor(ctx, server, session)
+	}
+	if err == nil {
+		steps.start("selecting the focused pane")
+		err = p.applyFocus(ctx, windowMap)
 	}
 	return
 }
+
+// focusedTask returns the first task in the window with focus enabled
+func (w Window) focusedTask(p Project) (TaskId, bool) {
+	for _, taskId := range w.Panes {
+		if p.Tasks[taskId].Focus {
+			return taskId, true
+		}
+	}
+	return "", false
+}
+
+// focusTarget returns the window to select after starting. The window
+// containing FocusTask takes precedence over a window with focus enabled, which
+// takes precedence over a window containing a task with focus enabled.
+func (p Project) focusTarget() (Window, error) {
+	if p.FocusTask != "" {
+		for _, w := range p.Windows {
+			if slices.Contains(w.Panes, p.FocusTask) {
+				return w, nil
+			}
+		}
+		return Window{}, fmt.Errorf("Task %q is not in any window", p.FocusTask)
+	}
+	for _, w := range p.Windows {
+		if w.Focus {
+			return w, nil
+		}
+	}
+	for _, w := range p.Windows {
+		if _, ok := w.focusedTask(p); ok {
+			return w, nil
+		}
+	}
+	return p.Windows[0], nil
+}
+
+// applyFocus selects the focused pane in each window, zooming it if
+// configured, and finally selects the focused window.
+func (p Project) applyFocus(ctx context.Context, windowMap TmuxWindowMap) error {
+	if len(p.Windows) == 0 {
+		return nil
+	}
+	target, err := p.focusTarget()
+	if err != nil {
+		return err
+	}
+	for _, w := range p.Windows {
+		taskId, ok := w.focusedTask(p)
+		if w.id == target.id && p.FocusTask != "" {
+			taskId, ok = p.FocusTask, true
+		}
+		if !ok {
+			continue
+		}
+		tmuxWindow := windowMap[w.id]
+		panes, err := tmuxWindow.GetPanes(ctx)
+		if err != nil {
+			return err
+		}
+		if pane := panes.FindByTitle(taskId); pane != nil {
+			if err = pane.Select(ctx); err == nil && p.Tasks[taskId].Zoom {
+				err = tmuxWindow.Zoom(ctx)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return windowMap[target.id].Select(ctx)
+}
@@ -1,12 +1,16 @@
 package main_test
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path"
 	"regexp"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/onsi/gomega"
 	. "github.com/onsi/gomega"
@@ -39,8 +43,9 @@ func (s *ProjectTestSuite) SetupTest() {
 }
 
 func (s *ProjectTestSuite) TearDownTest() {
+	ctx := context.Background()
 	for _, knownSession := range s.knownSessions {
-		s.server.KillSession(knownSession)
+		s.server.KillSession(ctx, knownSession)
 	}
 }
 
@@ -53,7 +58,8 @@ func TestProjectType(t *testing.T) {
 }
 
 func (s *ProjectTestSuite) TearDownSuite() {
-	err := s.server.KillServer()
+	ctx := context.Background()
+	err := s.server.KillServer(ctx)
 	s.Assert().Error(err, "If killing doesn't fail, a test did not clean up correctly")
 }
 
@@ -79,29 +85,32 @@ func TestProjectEnsureStarted(t *testing.T) {
 }
 
 func (s *ProjectEnsureStartedTestSuite) TestStartWhenNotAlreadyStarted() {
+	ctx := context.Background()
 	proj := CreateProject()
-	session := s.handleProjectStart(proj.EnsureStarted(s.server))
+	session := s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
 	s.Expect(session).To(BeStarted())
 }
 
 func (s *ProjectEnsureStartedTestSuite) TestStartProjectWithOnePane() {
+	ctx := context.Background()
 	proj := CreateProject()
-	session := s.handleProjectStart(proj.EnsureStarted(s.server))
+	session := s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
 
 	s.Expect(
-		session.GetPanes(),
+		session.GetPanes(ctx),
 	).To(HaveExactElements(HaveField("Id", MatchRegexp("^\\%\\d+$"))))
 }
 
 func (s *ProjectEnsureStartedTestSuite) TestWorkingDirectory() {
+	ctx := context.Background()
 	proj := CreateProject()
 	proj.WorkingDirectory = s.dir
-	session := s.handleProjectStart(proj.EnsureStarted(s.server))
-	cm := MustStartControlMode(s.server, session)
+	session := s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+	cm := MustStartControlMode(ctx, s.server, session)
 	defer cm.MustClose()
 
 	s.Expect(
-		session.RunShellCommand("echo $PWD"),
+		session.RunShellCommand(ctx, "echo $PWD"),
 	).To(Succeed())
 	s.Eventually(
 		s.getOutputLinesFromEvents(s.getOutputEvents(GetLines(cm.stdout))),
@@ -109,21 +118,22 @@ func (s *ProjectEnsureStartedTestSuite) TestWorkingDirectory() {
 }
 
 func (s *ProjectEnsureStartedTestSuite) TestWorkingDirectoryMultipleWindows() {
+	ctx := context.Background()
 	proj := CreateProjectWithWindowNames(
 		"Window-1",
 		"Window-2",
 		"Window-3",
 	)
 	proj.WorkingDirectory = s.dir
-	session := s.handleProjectStart(proj.EnsureStarted(s.server))
-	cm := MustStartControlMode(s.server, session)
+	session := s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+	cm := MustStartControlMode(ctx, s.server, session)
 	defer cm.MustClose()
 
 	outputStream := s.getOutputLinesFromEvents(s.getOutputEvents(GetLines(cm.stdout)))
-	windows := session.MustGetWindows()
+	windows := session.MustGetWindows(ctx)
 	for winNo, win := range windows {
 		s.Expect(
-			win.RunShellCommand("echo $PWD"),
+			win.RunShellCommand(ctx, "echo $PWD"),
 		).To(Succeed())
 		s.Eventually(
 			outputStream,
@@ -132,22 +142,23 @@ func (s *ProjectEnsureStartedTestSuite) TestWorkingDirectoryMultipleWindows() {
 }
 
 func (s *ProjectEnsureStartedTestSuite) TestWorkingDirectoryMultiplePanes() {
+	ctx := context.Background()
 	proj := CreateProject()
 	proj.AppendNamedWindow("Window-1").
 		AppendPane(proj.CreatePaneWithCommands("pane-1")).
 		AppendPane(proj.CreatePaneWithCommands("pane-2"))
 	proj.WorkingDirectory = s.dir
-	session := s.handleProjectStart(proj.EnsureStarted(s.server))
-	cm := MustStartControlMode(s.server, session)
+	session := s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+	cm := MustStartControlMode(ctx, s.server, session)
 	defer cm.MustClose()
 
 	outputStream := s.getOutputLinesFromEvents(s.getOutputEvents(GetLines(cm.stdout)))
-	windows := session.MustGetWindows()
+	windows := session.MustGetWindows(ctx)
 	for winNo, window := range windows {
-		panes := window.MustGetPanes()
+		panes := window.MustGetPanes(ctx)
 		for paneNo, pane := range panes {
 			s.Expect(
-				pane.RunShellCommand("echo $PWD"),
+				pane.RunShellCommand(ctx, "echo $PWD"),
 			).To(Succeed())
 			s.Eventually(
 				outputStream,
@@ -156,6 +167,7 @@ func (s *ProjectEnsureStartedTestSuite) TestWorkingDirectoryMultiplePanes() {
 	}
 }
 func (s *ProjectEnsureStartedTestSuite) TestWorkingFolderForTask() {
+	ctx := context.Background()
 	subdir := path.Join(s.dir, "sub_dir")
 	os.Mkdir(subdir, 0700)
 	defer func() { os.Remove(subdir) }()
@@ -166,25 +178,25 @@ func (s *ProjectEnsureStartedTestSuite) TestWorkingFolderForTask() {
 	win.AppendPane(pane1id)
 	win.AppendPane(pane2id)
 	proj.WorkingDirectory = s.dir
-	session := s.handleProjectStart(proj.EnsureStarted(s.server))
-	cm := MustStartControlMode(s.server, session)
+	session := s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+	cm := MustStartControlMode(ctx, s.server, session)
 	defer cm.MustClose()
 
 	outputStream := s.getOutputLinesFromEvents(s.getOutputEvents(GetLines(cm.stdout)))
-	windows := session.MustGetWindows()
+	windows := session.MustGetWindows(ctx)
 	for winNo, window := range windows {
-		panes := window.MustGetPanes()
+		panes := window.MustGetPanes(ctx)
 		pane1 := panes.FindByTitle(pane1id)
 		pane2 := panes.FindByTitle(pane2id)
 
 		s.Expect(
-			pane1.RunShellCommand("echo $PWD"),
+			pane1.RunShellCommand(ctx, "echo $PWD"),
 		).To(Succeed())
 		s.Eventually(
 			outputStream,
 		).Should(Receive(Equal(s.dir)), fmt.Sprintf("Win, pane no: %d, %d", winNo+1, 1))
 		s.Expect(
-			pane2.RunShellCommand("echo $PWD"),
+			pane2.RunShellCommand(ctx, "echo $PWD"),
 		).To(Succeed())
 		s.Eventually(
 			outputStream,
@@ -193,6 +205,7 @@ func (s *ProjectEnsureStartedTestSuite) TestWorkingFolderForTask() {
 }
 
 func (s *ProjectEnsureStartedTestSuite) TestTaskSubfolderInNewWindow() {
+	ctx := context.Background()
 	subdir := path.Join(s.dir, "sub_dir")
 	os.Mkdir(subdir, 0700)
 	defer func() { os.Remove(subdir) }()
@@ -202,34 +215,73 @@ func (s *ProjectEnsureStartedTestSuite) TestTaskSubfolderInNewWindow() {
 	proj.AppendNamedWindow("Window-1").AppendPane(pane1id)
 	proj.AppendNamedWindow("Window-2").AppendPane(pane2id)
 	proj.WorkingDirectory = s.dir
-	session := s.handleProjectStart(proj.EnsureStarted(s.server))
-	cm := MustStartControlMode(s.server, session)
+	session := s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+	cm := MustStartControlMode(ctx, s.server, session)
 	defer cm.MustClose()
 
 	outputStream := s.getOutputLinesFromEvents(s.getOutputEvents(GetLines(cm.stdout)))
 	panes := make(TmuxPanes, 0)
-	windows := session.MustGetWindows()
+	windows := session.MustGetWindows(ctx)
 	for _, window := range windows {
-		panes = append(panes, window.MustGetPanes()...)
+		panes = append(panes, window.MustGetPanes(ctx)...)
 	}
 	pane1 := panes.FindByTitle(pane1id)
 	pane2 := panes.FindByTitle(pane2id)
 
 	s.Expect(
-		pane1.RunShellCommand("echo $PWD"),
+		pane1.RunShellCommand(ctx, "echo $PWD"),
 	).To(Succeed())
 	s.Eventually(
 		outputStream,
 	).Should(Receive(Equal(s.dir)), fmt.Sprintf("Pane: %s", pane1id))
 	s.Expect(
-		pane2.RunShellCommand("echo $PWD"),
+		pane2.RunShellCommand(ctx, "echo $PWD"),
 	).To(Succeed())
 	s.Eventually(
 		outputStream,
 	).Should(Receive(Equal(subdir)), fmt.Sprintf("Pane: %s", pane2id))
 }
 
+func (s *ProjectEnsureStartedTestSuite) TestWindowWorkingDir() {
+	ctx := context.Background()
+	subdir := path.Join(s.dir, "sub_dir")
+	os.Mkdir(subdir, 0700)
+	defer func() { os.Remove(subdir) }()
+	proj := CreateProject(ProjectWorkingDir(s.dir))
+	pane1id := proj.CreatePane("pane-1")
+	pane2id := proj.CreatePane("pane-2")
+	win := proj.AppendNamedWindow("Window-1")
+	win.WorkingDirectory = "sub_dir"
+	win.AppendPane(pane1id)
+	win.AppendPane(pane2id)
+	proj.WorkingDirectory = s.dir
+	session := s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+	cm := MustStartControlMode(ctx, s.server, session)
+	defer cm.MustClose()
+
+	outputStream := s.getOutputLinesFromEvents(s.getOutputEvents(GetLines(cm.stdout)))
+	panes := session.MustGetWindows(ctx)[0].MustGetPanes(ctx)
+	for _, paneId := range []TaskId{pane1id, pane2id} {
+		s.Expect(
+			panes.FindByTitle(paneId).RunShellCommand(ctx, "echo $PWD"),
+		).To(Succeed())
+		s.Eventually(
+			outputStream,
+		).Should(Receive(Equal(subdir)), fmt.Sprintf("Pane: %s", paneId))
+	}
+}
+
+func (s *ProjectEnsureStartedTestSuite) TestMissingWorkingDirIsAnError() {
+	ctx := context.Background()
+	proj := CreateProject()
+	pane1id := proj.CreatePane("pane-1", TaskWorkingDir(path.Join(s.dir, "missing")))
+	proj.AppendNamedWindow("Window-1").AppendPane(pane1id)
+	_, err := proj.EnsureStarted(ctx, s.server)
+	s.Expect(err).To(MatchError(ContainSubstring("missing does not exist")))
+}
+
 func (s *ProjectEnsureStartedTestSuite) TestWorkingFolderForFirstTask() {
+	ctx := context.Background()
 	subdir := path.Join(s.dir, "sub_dir")
 	os.Mkdir(subdir, 0700)
 	defer func() { os.Remove(subdir) }()
@@ -240,25 +292,25 @@ func (s *ProjectEnsureStartedTestSuite) TestWorkingFolderForFirstTask() {
 	win.AppendPane(pane1id)
 	win.AppendPane(pane2id)
 	proj.WorkingDirectory = s.dir
-	session := s.handleProjectStart(proj.EnsureStarted(s.server))
-	cm := MustStartControlMode(s.server, session)
+	session := s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+	cm := MustStartControlMode(ctx, s.server, session)
 	defer cm.MustClose()
 
 	outputStream := s.getOutputLinesFromEvents(s.getOutputEvents(GetLines(cm.stdout)))
-	windows := session.MustGetWindows()
+	windows := session.MustGetWindows(ctx)
 	for winNo, window := range windows {
-		panes := window.MustGetPanes()
+		panes := window.MustGetPanes(ctx)
 		pane1 := panes.FindByTitle(pane1id)
 		pane2 := panes.FindByTitle(pane2id)
 
 		s.Expect(
-			pane1.RunShellCommand("echo $PWD"),
+			pane1.RunShellCommand(ctx, "echo $PWD"),
 		).To(Succeed())
 		s.Eventually(
 			outputStream,
 		).Should(Receive(Equal(subdir)), fmt.Sprintf("Win, pane no: %d, %d", winNo+1, 1))
 		s.Expect(
-			pane2.RunShellCommand("echo $PWD"),
+			pane2.RunShellCommand(ctx, "echo $PWD"),
 		).To(Succeed())
 		s.Eventually(
 			outputStream,
@@ -267,28 +319,31 @@ func (s *ProjectEnsureStartedTestSuite) TestWorkingFolderForFirstTask() {
 }
 
 func (s *ProjectEnsureStartedTestSuite) TestReturnSameSessionIfStarted() {
+	ctx := context.Background()
 	proj := CreateProject()
-	s1 := s.handleProjectStart(proj.EnsureStarted(s.server))
-	s2 := s.handleProjectStart(proj.EnsureStarted(s.server))
+	s1 := s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+	s2 := s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
 	s.Expect(s1.Id).To(Equal(s2.Id))
 }
 
 func (s *ProjectEnsureStartedTestSuite) TestWindowName() {
+	ctx := context.Background()
 	proj := CreateProjectWithWindowNames("Window-1")
-	s1 := s.handleProjectStart(proj.EnsureStarted(s.server))
+	s1 := s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
 	s.Expect(
-		s.server.GetWindowsForSession(s1),
+		s.server.GetWindowsForSession(ctx, s1),
 	).To(HaveExactElements(HaveField("Name", "Window-1")))
 }
 
 func (s *ProjectEnsureStartedTestSuite) TestMultipleWindows() {
+	ctx := context.Background()
 	proj := CreateProjectWithWindowNames(
 		"Window-1",
 		"Window-2",
 		"Window-3",
 	)
-	session := s.handleProjectStart(proj.EnsureStarted(s.server))
-	windows, err := s.server.GetWindowsForSession(session)
+	session := s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+	windows, err := s.server.GetWindowsForSession(ctx, session)
 	s.Expect(err).ToNot(HaveOccurred())
 	s.Expect(windows).To(HaveExactElements(
 		HaveField("Name", "Window-1"),
@@ -296,21 +351,22 @@ func (s *ProjectEnsureStartedTestSuite) TestMultipleWindows() {
 		HaveField("Name", "Window-3"),
 	))
 
-	s.Expect(s.server.GetCurrentWindowIndexForSession(session)).To(Equal(0))
-	s.Expect(windows[0].Index()).To(Equal(0))
-	s.Expect(windows[1].Index()).To(Equal(1))
-	s.Expect(windows[2].Index()).To(Equal(2))
+	s.Expect(s.server.GetCurrentWindowIndexForSession(ctx, session)).To(Equal(0))
+	s.Expect(windows[0].Index(ctx)).To(Equal(0))
+	s.Expect(windows[1].Index(ctx)).To(Equal(1))
+	s.Expect(windows[2].Index(ctx)).To(Equal(2))
 }
 
 func (s *ProjectEnsureStartedTestSuite) TestRecreateMissingWindowsOnRunningSession() {
+	ctx := context.Background()
 	proj := CreateProjectWithWindowNames(
 		"Window-1",
 		"Window-2",
 	)
-	session := s.handleProjectStart(proj.EnsureStarted(s.server))
+	session := s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
 	proj.AppendNamedWindow("Window-3")
-	s.handleProjectStart(proj.EnsureStarted(s.server))
-	s.Expect(s.server.GetWindowsForSession(session)).To(HaveExactElements(
+	s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+	s.Expect(s.server.GetWindowsForSession(ctx, session)).To(HaveExactElements(
 		HaveField("Name", "Window-1"),
 		HaveField("Name", "Window-2"),
 		HaveField("Name", "Window-3"),
@@ -318,11 +374,12 @@ func (s *ProjectEnsureStartedTestSuite) TestRecreateMissingWindowsOnRunningSessi
 }
 
 func (s *ProjectEnsureStartedTestSuite) TestRecreateWindowsOutOfOrder() {
+	ctx := context.Background()
 	proj := CreateProjectWithWindowNames("Window-4", "Window-1", "Window-3")
-	session := s.handleProjectStart(proj.EnsureStarted(s.server))
+	session := s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
 	proj.ReplaceWindowNames("Window-1", "Window-2", "Window-3", "Window-4")
-	s.handleProjectStart(proj.EnsureStarted(s.server))
-	s.Expect(s.server.GetWindowsForSession(session)).To(HaveExactElements(
+	s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+	s.Expect(s.server.GetWindowsForSession(ctx, session)).To(HaveExactElements(
 		HaveField("Name", "Window-1"),
 		HaveField("Name", "Window-2"),
 		HaveField("Name", "Window-3"),
@@ -331,17 +388,19 @@ func (s *ProjectEnsureStartedTestSuite) TestRecreateWindowsOutOfOrder() {
 }
 
 func (s *ProjectEnsureStartedTestSuite) TestRecreateMissingWindowsAgain_IsThisADuplicateTest() {
+	ctx := context.Background()
 	proj := CreateProjectWithWindowNames("Window-2")
-	session := s.handleProjectStart(proj.EnsureStarted(s.server))
+	session := s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
 	proj.ReplaceWindowNames("Window-1", "Window-2")
-	s.handleProjectStart(proj.EnsureStarted(s.server))
-	s.Expect(s.server.GetWindowsForSession(session)).To(HaveExactElements(
+	s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+	s.Expect(s.server.GetWindowsForSession(ctx, session)).To(HaveExactElements(
 		HaveField("Name", "Window-1"),
 		HaveField("Name", "Window-2"),
 	))
 }
 
 func (s *ProjectEnsureStartedTestSuite) TestCreatePanesWithCorrectNames() {
+	ctx := context.Background()
 	proj := CreateProject()
 	proj.AppendNamedWindow("Window-1").
 		AppendPane(proj.CreatePaneWithCommands("Pane-1")).
@@ -349,33 +408,35 @@ func (s *ProjectEnsureStartedTestSuite) TestCreatePanesWithCorrectNames() {
 	proj.AppendNamedWindow("Window-2").
 		AppendPane(proj.CreatePaneWithCommands("Pane-3")).
 		AppendPane(proj.CreatePaneWithCommands("Pane-4"))
-	s.handleProjectStart(proj.EnsureStarted(s.server))
+	session := s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
 	expected := []T{
 		{"Window-1", "Pane-1"},
 		{"Window-1", "Pane-2"},
 		{"Window-2", "Pane-3"},
 		{"Window-2", "Pane-4"},
 	}
-	s.Expect(s.server.GetWindowAndPaneNames()).To(HaveExactElements(expected))
+	s.Expect(session.GetWindowAndPaneNames(ctx)).To(HaveExactElements(expected))
 }
 
 func (s *ProjectEnsureStartedTestSuite) TestPaneLayoutTopBottomLeftRight() {
+	ctx := context.Background()
 	proj := CreateProject()
-	session := s.handleProjectStart(proj.EnsureStarted(s.server))
-	panes := session.MustGetPanes()
+	session := s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+	panes := session.MustGetPanes(ctx)
 	layout := panes[0].Layout
 	s.Expect(layout.Top).To(BeNumerically("<", layout.Bottom), "Top < Bottom")
 	s.Expect(layout.Left).To(BeNumerically("<", layout.Right), "Left < Right")
 }
 
 func (s *ProjectEnsureStartedTestSuite) TestHorizontalLayout() {
+	ctx := context.Background()
 	proj := CreateProject()
 	proj.AppendNamedWindow("Window-1").
 		SetHorizontalLayout().
 		AppendPane(proj.CreatePaneWithCommands("Pane-1")).
 		AppendPane(proj.CreatePaneWithCommands("Pane-2"))
-	session := s.handleProjectStart(proj.EnsureStarted(s.server))
-	panes := session.MustGetPanes()
+	session := s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+	panes := session.MustGetPanes(ctx)
 	s.Expect(panes[0].Title).To(Equal("Pane-1"))
 	s.Expect(panes[1].Title).To(Equal("Pane-2"))
 	s.Expect(panes[0].Layout.Top).To(Equal(0), "First pane top")
@@ -384,20 +445,436 @@ func (s *ProjectEnsureStartedTestSuite) TestHorizontalLayout() {
 	s.Expect(panes[1].Layout.Left).ToNot(Equal(0), "Second pane left")
 }
 func (s *ProjectEnsureStartedTestSuite) TestVerticalLayout() {
+	ctx := context.Background()
 	proj := CreateProject()
 	proj.AppendNamedWindow("Window-1").
 		SetVerticalLayout().
 		AppendPane(proj.CreatePaneWithCommands("Pane-1")).
 		AppendPane(proj.CreatePaneWithCommands("Pane-2"))
-	session := s.handleProjectStart(proj.EnsureStarted(s.server))
-	panes := session.MustGetPanes()
+	session := s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+	panes := session.MustGetPanes(ctx)
 	s.Expect(panes[0].Layout.Top).To(Equal(0), "First pane top")
 	s.Expect(panes[0].Layout.Left).To(Equal(0), "First pane left")
 	s.Expect(panes[1].Layout.Top).ToNot(Equal(0), "Second pane top")
 	s.Expect(panes[1].Layout.Left).To(Equal(0), "Second pane left")
 }
 
+// activePane returns the name of the active window, the title of its active
+// pane, and whether the window is zoomed.
+func (s *ProjectTestSuite) activePane(ctx context.Context, session TmuxSession) string {
+	output, err := s.server.Command(
+		ctx,
+		"display-message", "-p", "-t", session.Id,
+		"#{window_name}:#{pane_title}:#{window_zoomed_flag}",
+	).Output()
+	s.Expect(err).ToNot(HaveOccurred())
+	return strings.TrimSpace(string(output))
+}
+
+func (s *ProjectEnsureStartedTestSuite) TestFocusFirstWindowByDefault() {
+	ctx := context.Background()
+	proj := CreateProject()
+	proj.AppendNamedWindow("Window-1").
+		AppendPane(proj.CreatePane("Pane-1")).
+		AppendPane(proj.CreatePane("Pane-2"))
+	proj.AppendNamedWindow("Window-2").AppendPane(proj.CreatePane("Pane-3"))
+	session := s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+	s.Expect(s.activePane(ctx, session)).To(Equal("Window-1:Pane-2:0"))
+}
+
+func (s *ProjectEnsureStartedTestSuite) TestFocusWindowAndPane() {
+	ctx := context.Background()
+	proj := CreateProject()
+	proj.AppendNamedWindow("Window-1").AppendPane(proj.CreatePane("Pane-1"))
+	editor := proj.CreatePane("Editor")
+	proj.Tasks[editor] = Task{Focus: true, Zoom: true}
+	proj.AppendNamedWindow("Window-2").
+		AppendPane(editor).
+		AppendPane(proj.CreatePane("Pane-3"))
+	session := s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+	s.Expect(s.activePane(ctx, session)).To(Equal("Window-2:Editor:1"))
+
+	// Starting again doesn't toggle the zoom
+	s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+	s.Expect(s.activePane(ctx, session)).To(Equal("Window-2:Editor:1"))
+}
+
+func (s *ProjectEnsureStartedTestSuite) TestFocusTaskOverridesConfiguration() {
+	ctx := context.Background()
+	proj := CreateProject()
+	proj.AppendNamedWindow("Window-1").
+		AppendPane(proj.CreatePane("Pane-1")).
+		AppendPane(proj.CreatePane("Pane-2"))
+	proj.AppendNamedWindow("Window-2").AppendPane(proj.CreatePane("Pane-3"))
+	proj.Windows[1].Focus = true
+	proj.FocusTask = "Pane-1"
+	session := s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+	s.Expect(s.activePane(ctx, session)).To(Equal("Window-1:Pane-1:0"))
+
+	proj.FocusTask = "Unknown"
+	_, err := proj.EnsureStarted(ctx, s.server)
+	s.Expect(err).To(MatchError(`Task "Unknown" is not in any window`))
+}
+
+func (s *ProjectTestSuite) tmuxOutput(ctx context.Context, args ...string) string {
+	output, err := s.server.Command(ctx, args...).Output()
+	s.Expect(err).ToNot(HaveOccurred())
+	return strings.TrimSpace(string(output))
+}
+
+func (s *ProjectEnsureStartedTestSuite) TestReconcileOptionsAndBindings() {
+	ctx := context.Background()
+	proj := CreateProject()
+	server := proj.CreatePane("server")
+	proj.AppendNamedWindow("Window-1").AppendPane(server)
+	proj.Options = map[string]string{"status-left": "muxify-test"}
+	proj.Windows[0].Options = map[string]string{"synchronize-panes": "on"}
+	proj.Tasks[server] = Task{Options: map[string]string{"remain-on-exit": "on"}}
+	proj.Bindings = map[string]string{"F12": "restart server"}
+	session := s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+	window := session.MustGetWindows(ctx)[0]
+	pane := window.MustGetPanes(ctx)[0]
+
+	s.Expect(s.tmuxOutput(ctx, "show-options", "-v", "-t", session.Id, "status-left")).
+		To(Equal("muxify-test"))
+	s.Expect(s.tmuxOutput(ctx, "show-options", "-w", "-v", "-t", window.Id, "synchronize-panes")).
+		To(Equal("on"))
+	s.Expect(s.tmuxOutput(ctx, "show-options", "-p", "-v", "-t", pane.Id, "remain-on-exit")).
+		To(Equal("on"))
+	s.Expect(s.tmuxOutput(ctx, "list-keys", "-T", "prefix", "F12")).To(ContainSubstring("__key"))
+
+	proj.Options = nil
+	proj.Windows[0].Options = nil
+	proj.Tasks[server] = Task{}
+	proj.Bindings = nil
+	s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+
+	s.Expect(s.tmuxOutput(ctx, "show-options", "-q", "-v", "-t", session.Id, "status-left")).
+		To(BeEmpty())
+	s.Expect(s.tmuxOutput(ctx, "show-options", "-q", "-w", "-v", "-t", window.Id, "synchronize-panes")).
+		To(BeEmpty())
+	s.Expect(s.tmuxOutput(ctx, "show-options", "-q", "-p", "-v", "-t", pane.Id, "remain-on-exit")).
+		To(BeEmpty())
+	s.Expect(s.tmuxOutput(ctx, "list-keys", "-T", "prefix")).ToNot(ContainSubstring("F12"))
+}
+
+func (s *ProjectEnsureStartedTestSuite) TestRemovedBindingRestoresUserBinding() {
+	ctx := context.Background()
+	proj := CreateProject()
+	server := proj.CreatePane("server")
+	proj.AppendNamedWindow("Window-1").AppendPane(server)
+	s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+	s.tmuxOutput(ctx, "bind-key", "-T", "prefix", "F11", "display-message", "#{session_name}")
+
+	proj.Bindings = map[string]string{"F11": "restart server"}
+	s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+	s.Expect(s.tmuxOutput(ctx, "list-keys", "-T", "prefix", "F11")).To(ContainSubstring("__key"))
+
+	proj.Bindings = nil
+	s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+
+	s.Expect(s.tmuxOutput(ctx, "list-keys", "-T", "prefix", "F11")).
+		To(Equal(`bind-key -T prefix F11 display-message "#{session_name}"`))
+}
+
+func (s *ProjectEnsureStartedTestSuite) TestRunSavedBinding() {
+	ctx := context.Background()
+	proj := CreateProject()
+	server := proj.CreatePane("server")
+	proj.AppendNamedWindow("Window-1").AppendPane(server)
+	s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+	s.tmuxOutput(ctx, "bind-key", "-r", "-T", "prefix", "F10", "set-option", "-g", "@muxify-test", "pressed")
+	proj.Bindings = map[string]string{"F10": "restart server"}
+	s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+
+	found, err := s.server.RunSavedBinding(ctx, "F10")
+	s.Expect(err).ToNot(HaveOccurred())
+	s.Expect(found).To(BeTrue())
+	s.Expect(s.tmuxOutput(ctx, "show-options", "-g", "-v", "@muxify-test")).To(Equal("pressed"))
+
+	found, err = s.server.RunSavedBinding(ctx, "F9")
+	s.Expect(err).ToNot(HaveOccurred())
+	s.Expect(found).To(BeFalse())
+}
+
+func (s *ProjectEnsureStartedTestSuite) TestSendToAndRestartTask() {
+	ctx := context.Background()
+	proj := CreateProject()
+	server := proj.CreatePaneWithCommands("server", "echo started-$((1 + 1))")
+	proj.AppendNamedWindow("Window-1").AppendPane(server)
+	session := s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+	s.Eventually(s.paneContent(ctx, session, server)).Should(ContainSubstring("started-2"))
+
+	s.Expect(proj.SendToTask(ctx, s.server, server, "echo sent-$((1 + 2))")).To(Succeed())
+	s.Eventually(s.paneContent(ctx, session, server)).Should(ContainSubstring("sent-3"))
+
+	s.Expect(proj.RestartTask(ctx, s.server, server)).To(Succeed())
+	s.Eventually(s.paneContent(ctx, session, server)).Should(And(
+		ContainSubstring("started-2"),
+		Not(ContainSubstring("sent-3")),
+	))
+}
+
+// startSupervisedTask starts a task appending a line to the file every time it
+// starts, and exits with status 3.
+func (s *ProjectEnsureStartedTestSuite) startSupervisedTask(
+	ctx context.Context,
+	policy RestartPolicy,
+	file string,
+) (TmuxSession, TmuxPane) {
+	proj := CreateProject()
+	server := proj.CreatePaneWithCommands("server", "echo starting >> "+file, "exit 3")
+	proj.Tasks[server] = Task{Commands: proj.Tasks[server].Commands, Restart: policy}
+	proj.AppendNamedWindow("Window-1").AppendPane(server)
+	session := s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+	pane := session.MustGetWindows(ctx)[0].MustGetPanes(ctx).FindByTitle(server)
+	s.Expect(pane).ToNot(BeNil())
+	s.Expect(s.tmuxOutput(ctx, "show-hooks", "-t", session.Id, "pane-died")).
+		To(ContainSubstring("__pane-died"))
+	s.Eventually(func() string {
+		return s.tmuxOutput(ctx, "display-message", "-p", "-t", pane.Id, "#{pane_dead}")
+	}).Should(Equal("1"))
+	return session, *pane
+}
+
+func (s *ProjectEnsureStartedTestSuite) paneOption(ctx context.Context, pane TmuxPane, name string) string {
+	return s.tmuxOutput(ctx, "display-message", "-p", "-t", pane.Id, "#{"+name+"}")
+}
+
+func (s *ProjectEnsureStartedTestSuite) TestRestartFailedTask() {
+	ctx := context.Background()
+	file := path.Join(s.dir, "starts")
+	defer os.Remove(file)
+	_, pane := s.startSupervisedTask(ctx, RestartOnFailure, file)
+	s.Expect(s.server.HandlePaneDied(ctx, pane.Id)).To(Succeed())
+	s.Expect(s.paneOption(ctx, pane, "@muxify_exit_status")).To(Equal("3"))
+	s.Expect(s.paneOption(ctx, pane, "@muxify_restarts")).To(Equal("1"))
+	s.Eventually(func() string {
+		starts, _ := os.ReadFile(file)
+		return string(starts)
+	}).Should(Equal("starting\nstarting\n"))
+}
+
+func (s *ProjectEnsureStartedTestSuite) TestCancelRestartDelay() {
+	ctx := context.Background()
+	file := path.Join(s.dir, "starts")
+	defer os.Remove(file)
+	_, pane := s.startSupervisedTask(ctx, RestartOnFailure, file)
+	timeoutCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	s.Expect(s.server.HandlePaneDied(timeoutCtx, pane.Id)).To(MatchError(context.DeadlineExceeded))
+	s.Expect(time.Since(start)).To(BeNumerically("<", 400*time.Millisecond), "Stops waiting to restart")
+	s.Expect(s.paneOption(ctx, pane, "@muxify_restarts")).To(Equal("0"))
+	s.Expect(s.paneOption(ctx, pane, "pane_dead")).To(Equal("1"))
+}
+
+func (s *ProjectEnsureStartedTestSuite) TestDontRestartTaskWithPolicyNever() {
+	ctx := context.Background()
+	file := path.Join(s.dir, "starts")
+	defer os.Remove(file)
+	_, pane := s.startSupervisedTask(ctx, RestartNever, file)
+	s.Expect(s.server.HandlePaneDied(ctx, pane.Id)).To(Succeed())
+	s.Expect(s.paneOption(ctx, pane, "@muxify_exit_status")).To(Equal("3"))
+	s.Expect(s.paneOption(ctx, pane, "@muxify_restarts")).To(Equal("0"))
+	s.Expect(s.paneOption(ctx, pane, "pane_dead")).To(Equal("1"))
+}
+
+func (s *ProjectEnsureStartedTestSuite) TestExecTaskRunsProgramAsPaneProcess() {
+	ctx := context.Background()
+	proj := CreateProject()
+	proj.AppendNamedWindow("Window-1").
+		AppendPane(proj.CreatePane("program", TaskExec("sleep 100"))).
+		AppendPane(proj.CreatePane("kept", TaskExec("echo done"), TaskKeepOpen()))
+	session := s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+	panes := session.MustGetPanes(ctx)
+
+	s.Expect(s.paneOption(ctx, panes[0], "pane_start_command")).To(Equal(`"sleep 100"`))
+	s.Eventually(func() string { return s.paneOption(ctx, panes[1], "pane_dead") }).Should(Equal("1"))
+	s.Expect(s.paneContent(ctx, session, "kept")()).To(ContainSubstring("done"))
+	s.Expect(s.paneContent(ctx, session, "kept")()).ToNot(ContainSubstring("echo"),
+		"The program isn't typed into a shell")
+
+	task := proj.Tasks["program"]
+	task.Exec = "sleep 200"
+	proj.Tasks["program"] = task
+	status, err := s.server.GetProjectStatus(ctx, proj.Project)
+	s.Expect(err).ToNot(HaveOccurred())
+	s.Expect(status.Windows[0].Panes).To(HaveExactElements(
+		HaveField("CommandsChanged", true),
+		And(HaveField("Task", "kept"), HaveField("Exited", true)),
+	))
+}
+
+func (s *ProjectEnsureStartedTestSuite) TestExecTaskPaneIsCreatedRunningProgram() {
+	ctx := context.Background()
+	proj := CreateProject()
+	proj.AppendNamedWindow("Window-1").
+		AppendPane(proj.CreatePane("first", TaskExec("sleep 100")))
+	session := s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+	marker := path.Join(s.dir, "shell-started")
+	defer os.Remove(marker)
+	s.tmuxOutput(ctx, "set-option", "-t", session.Id, "default-command", "touch "+marker+"; exec sh")
+
+	proj.Windows[0].Panes = append(proj.Windows[0].Panes, proj.CreatePane("split", TaskExec("sleep 100")))
+	proj.AppendNamedWindow("Window-2").
+		AppendPane(proj.CreatePane("window", TaskExec("sleep 100"), TaskKeepOpen()))
+	s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+
+	s.Expect(session.GetWindowAndPaneNames(ctx)).To(HaveExactElements([]T{
+		{"Window-1", "first"},
+		{"Window-1", "split"},
+		{"Window-2", "window"},
+	}))
+	s.gomega.Consistently(func() string { return marker }, "200ms").ShouldNot(BeAnExistingFile(),
+		"No shell is started for the programs")
+}
+
+func (s *ProjectEnsureStartedTestSuite) TestRemovingKeepOpenUnsetsRemainOnExit() {
+	ctx := context.Background()
+	proj := CreateProject()
+	proj.AppendNamedWindow("Window-1").
+		AppendPane(proj.CreatePane("program", TaskExec("sleep 100"), TaskKeepOpen()))
+	session := s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+	pane := session.MustGetPanes(ctx)[0]
+	s.Expect(s.tmuxOutput(ctx, "show-options", "-p", "-v", "-t", pane.Id, "remain-on-exit")).
+		To(Equal("on"))
+
+	task := proj.Tasks["program"]
+	task.KeepOpen = false
+	proj.Tasks["program"] = task
+	s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+	s.Expect(s.tmuxOutput(ctx, "show-options", "-q", "-p", "-v", "-t", pane.Id, "remain-on-exit")).
+		To(BeEmpty())
+}
+
+func (s *ProjectEnsureStartedTestSuite) TestPaneClosesWhenProgramExits() {
+	ctx := context.Background()
+	proj := CreateProject()
+	proj.AppendNamedWindow("Window-1").
+		AppendPane(proj.CreatePaneWithCommands("Pane-1")).
+		AppendPane(proj.CreatePane("exits", TaskExec("true")))
+	proj.AppendNamedWindow("Window-2").
+		AppendPane(proj.CreatePane("exits-too", TaskExec("true"))).
+		AppendPane(proj.CreatePaneWithCommands("Pane-2"))
+	session := s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+
+	s.Eventually(session.GetWindowAndPaneNames).WithContext(ctx).Should(HaveExactElements([]T{
+		{"Window-1", "Pane-1"},
+		{"Window-2", "Pane-2"},
+	}))
+}
+
+func (s *ProjectEnsureStartedTestSuite) TestTaskShell() {
+	ctx := context.Background()
+	shell := "env MUXIFY_SHELL=custom sh"
+	proj := CreateProject()
+	proj.AppendNamedWindow("Window-1").
+		AppendPane(proj.CreatePane("shell", TaskShell(shell), TaskCommands("echo shell:$MUXIFY_SHELL"))).
+		AppendPane(proj.CreatePane("exec", TaskShell(shell), TaskExec("echo exec:$MUXIFY_SHELL"), TaskKeepOpen()))
+	session := s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+
+	s.Eventually(s.paneContent(ctx, session, "shell")).Should(ContainSubstring("shell:custom"))
+	s.Eventually(s.paneContent(ctx, session, "exec")).Should(ContainSubstring("exec:custom"))
+}
+
+// slowShell is a shell discarding the keystrokes typed while it starts, like
+// shells with slow startup files may do.
+const slowShell = "sh -c 'sleep 0.5; timeout 0.2 cat >/dev/null; exec sh'"
+
+func (s *ProjectEnsureStartedTestSuite) TestWaitForShellBeforeTypingCommands() {
+	ctx := context.Background()
+	proj := CreateProject()
+	proj.AppendNamedWindow("Window-1").
+		AppendPane(proj.CreatePane("slow", TaskShell(slowShell), TaskCommands("echo ty''ped")))
+	session := s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+
+	s.Eventually(s.paneContent(ctx, session, "slow")).Should(ContainSubstring("\ntyped"))
+}
+
+func (s *ProjectEnsureStartedTestSuite) TestWaitForTaskPrompt() {
+	ctx := context.Background()
+	shell := `sh -c 'echo loading; sleep 0.5; timeout 0.2 cat >/dev/null; PS1="ready> " exec sh'`
+	proj := CreateProject()
+	proj.AppendNamedWindow("Window-1").
+		AppendPane(proj.CreatePane("slow", TaskShell(shell), TaskPrompt("^ready>"), TaskCommands("echo ty''ped")))
+	session := s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+
+	s.Eventually(s.paneContent(ctx, session, "slow")).Should(ContainSubstring("\ntyped"))
+}
+
+func (s *ProjectEnsureStartedTestSuite) TestDontWaitForExitedShells() {
+	ctx := context.Background()
+	proj := CreateProject()
+	proj.AppendNamedWindow("Window-1").
+		AppendPane(proj.CreatePaneWithCommands("Pane-1")).
+		AppendPane(proj.CreatePane("closed", TaskShell("true"), TaskPrompt("^never>"), TaskCommands("echo typed"))).
+		AppendPane(proj.CreatePane("dead", TaskShell("true"), TaskKeepOpen(), TaskPrompt("^never>"), TaskCommands("echo typed")))
+	start := time.Now()
+	session := s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+
+	s.Expect(time.Since(start)).To(BeNumerically("<", 5*time.Second), "Exited shells never get ready")
+	s.Expect(session.GetWindowAndPaneNames(ctx)).To(HaveExactElements([]T{
+		{"Window-1", "Pane-1"},
+		{"Window-1", "dead"},
+	}))
+}
+
+func (s *ProjectEnsureStartedTestSuite) TestInvalidTaskPromptIsAnError() {
+	ctx := context.Background()
+	proj := CreateProject()
+	proj.AppendNamedWindow("Window-1").
+		AppendPane(proj.CreatePane("shell", TaskPrompt("[")))
+	_, err := proj.EnsureStarted(ctx, s.server)
+	s.Expect(err).To(MatchError(ContainSubstring("Invalid prompt")))
+}
+
+func (s *ProjectEnsureStartedTestSuite) TestStatusReportsDrift() {
+	ctx := context.Background()
+	proj := CreateProject()
+	editor := proj.CreatePaneWithCommands("editor", "cat")
+	build := proj.CreatePaneWithCommands("build", "true")
+	server := proj.CreatePane("server")
+	proj.Tasks[server] = Task{Exec: "true", KeepOpen: true}
+	logs := proj.CreatePane("logs")
+	proj.AppendNamedWindow("Window-1").AppendPane(editor).AppendPane(build).AppendPane(server)
+	proj.AppendNamedWindow("Window-2").AppendPane(logs)
+	session := s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+
+	status, err := s.server.GetProjectStatus(ctx, proj.Project)
+	s.Expect(err).ToNot(HaveOccurred())
+	s.Expect(status.Running).To(BeTrue())
+	s.Eventually(func() []PaneStatus {
+		status, _ := s.server.GetProjectStatus(ctx, proj.Project)
+		return status.Windows[0].Panes
+	}).Should(HaveExactElements(
+		And(HaveField("Task", editor), HaveField("Command", "cat"), HaveField("Exited", false)),
+		And(HaveField("Task", build), HaveField("Command", "sh"), HaveField("Exited", false)),
+		And(HaveField("Task", server), HaveField("Exited", true)),
+	))
+	status, err = s.server.GetProjectStatus(ctx, proj.Project)
+	s.Expect(err).ToNot(HaveOccurred())
+	s.Expect(status.HasDrift()).To(BeTrue())
+
+	proj.Tasks[server] = Task{}
+	proj.Tasks[editor] = Task{Commands: Commands{"vi"}}
+	window, _ := session.MustGetWindows(ctx).FindByName("Window-2")
+	s.Expect(s.server.KillWindow(ctx, window)).To(Succeed())
+	status, err = s.server.GetProjectStatus(ctx, proj.Project)
+	s.Expect(err).ToNot(HaveOccurred())
+	s.Expect(status.Windows[0].Panes).To(HaveExactElements(
+		And(HaveField("Task", editor), HaveField("CommandsChanged", true)),
+		And(HaveField("Task", build), HaveField("CommandsChanged", false)),
+		And(HaveField("Task", server), HaveField("CommandsChanged", true)),
+	))
+	s.Expect(status.Windows[1]).To(And(HaveField("Name", "Window-2"), HaveField("Missing", true)))
+
+	s.Expect(s.server.GetProjectStatus(ctx, CreateProject().Project)).
+		To(HaveField("Running", false))
+}
+
 func (s *ProjectEnsureStartedTestSuite) TestEnsureStartedDoesntAddMorePanes() {
+	ctx := context.Background()
 	proj := CreateProject()
 	proj.AppendNamedWindow("Window-1").
 		AppendPane(proj.CreatePaneWithCommands("Pane-1")).
@@ -405,8 +882,8 @@ func (s *ProjectEnsureStartedTestSuite) TestEnsureStartedDoesntAddMorePanes() {
 	proj.AppendNamedWindow("Window-2").
 		AppendPane(proj.CreatePaneWithCommands("Pane-3")).
 		AppendPane(proj.CreatePaneWithCommands("Pane-4"))
-	s.handleProjectStart(proj.EnsureStarted(s.server))
-	s.handleProjectStart(proj.EnsureStarted(s.server))
+	s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+	session := s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
 
 	expected := []T{
 		{"Window-1", "Pane-1"},
@@ -414,68 +891,455 @@ func (s *ProjectEnsureStartedTestSuite) TestEnsureStartedDoesntAddMorePanes() {
 		{"Window-2", "Pane-3"},
 		{"Window-2", "Pane-4"},
 	}
-	s.Expect(s.server.GetWindowAndPaneNames()).To(HaveExactElements(expected))
+	s.Expect(session.GetWindowAndPaneNames(ctx)).To(HaveExactElements(expected))
+}
+
+// panesLeftToRight returns the titles of the panes in the window ordered left to
+// right, verifying that they are side by side.
+func (s *ProjectEnsureStartedTestSuite) panesLeftToRight(ctx context.Context, window TmuxWindow) []string {
+	var titles []string
+	right := -1
+	for _, pane := range window.MustGetPanes(ctx) {
+		s.Expect(pane.Layout.Left).To(BeNumerically(">", right), pane.Title)
+		s.Expect(pane.Layout.Top).To(Equal(0), pane.Title)
+		titles = append(titles, pane.Title)
+		right = pane.Layout.Right
+	}
+	return titles
+}
+
+func (s *ProjectEnsureStartedTestSuite) TestReorderPanes() {
+	ctx := context.Background()
+	proj := CreateProject()
+	proj.AppendNamedWindow("Window-1").
+		AppendPane(proj.CreatePaneWithCommands("Pane-1")).
+		AppendPane(proj.CreatePaneWithCommands("Pane-2")).
+		AppendPane(proj.CreatePaneWithCommands("Pane-3"))
+	session := s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+	window := session.MustGetWindows(ctx)[0]
+	ids := window.MustGetPanes(ctx)
+
+	proj.Windows[0].Panes = []TaskId{"Pane-3", "Pane-1", "Pane-2"}
+	s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+	s.Expect(s.panesLeftToRight(ctx, window)).To(Equal([]string{"Pane-3", "Pane-1", "Pane-2"}))
+	s.Expect(window.MustGetPanes(ctx)).To(ConsistOf(
+		HaveField("Id", ids[0].Id), HaveField("Id", ids[1].Id), HaveField("Id", ids[2].Id),
+	), "Panes are moved, not recreated")
+}
+
+func (s *ProjectEnsureStartedTestSuite) TestSwapDraggedPaneBack() {
+	ctx := context.Background()
+	proj := CreateProject()
+	proj.AppendNamedWindow("Window-1").
+		AppendPane(proj.CreatePaneWithCommands("Pane-1")).
+		AppendPane(proj.CreatePaneWithCommands("Pane-2")).
+		AppendPane(proj.CreatePaneWithCommands("Pane-3"))
+	session := s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+	window := session.MustGetWindows(ctx)[0]
+	panes := window.MustGetPanes(ctx)
+	// Stack the last pane below the first
+	s.server.Command(ctx, "move-pane", "-v", "-s", panes[2].Id, "-t", panes[0].Id).MustOutput()
+
+	s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+	panes = window.MustGetPanes(ctx)
+	s.Expect(panes).To(HaveExactElements(
+		HaveField("Title", "Pane-1"), HaveField("Title", "Pane-2"), HaveField("Title", "Pane-3"),
+	))
+	s.Expect(panes[1].Layout.Left).To(Equal(0), "The stacked layout is kept")
+	s.Expect(panes[1].Layout.Top).ToNot(Equal(0), "The stacked layout is kept")
+}
+
+func (s *ProjectEnsureStartedTestSuite) TestKeepResizedAndZoomedPanes() {
+	ctx := context.Background()
+	proj := CreateProject()
+	proj.AppendNamedWindow("Window-1").
+		AppendPane(proj.CreatePaneWithCommands("Pane-1")).
+		AppendPane(proj.CreatePaneWithCommands("Pane-2")).
+		AppendPane(proj.CreatePaneWithCommands("Pane-3"))
+	session := s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+	window := session.MustGetWindows(ctx)[0]
+	panes := window.MustGetPanes(ctx)
+	s.server.Command(ctx, "resize-pane", "-t", panes[0].Id, "-x", "10").MustOutput()
+	resized := window.MustGetPanes(ctx)
+
+	s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+	s.Expect(window.MustGetPanes(ctx)).To(Equal(resized), "Resized panes are kept")
+
+	s.server.Command(ctx, "resize-pane", "-Z", "-t", panes[1].Id).MustOutput()
+	s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+	s.Expect(s.activePane(ctx, session)).To(Equal("Window-1:Pane-2:1"), "Zoom is kept")
+
+	proj.Windows[0].Panes = []TaskId{"Pane-3", "Pane-1", "Pane-2"}
+	s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+	s.Expect(s.activePane(ctx, session)).To(Equal("Window-1:Pane-2:1"), "Zoomed windows are not rearranged")
+}
+
+func (s *ProjectEnsureStartedTestSuite) TestMoveTaskToAnotherWindow() {
+	ctx := context.Background()
+	proj := CreateProject()
+	proj.AppendNamedWindow("Window-1").
+		AppendPane(proj.CreatePaneWithCommands("Pane-1")).
+		AppendPane(proj.CreatePaneWithCommands("Pane-2"))
+	proj.AppendNamedWindow("Window-2").
+		AppendPane(proj.CreatePaneWithCommands("Pane-3"))
+	session := s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+	moved := session.MustGetWindows(ctx)[0].MustGetPanes(ctx).FindByTitle("Pane-2")
+
+	proj.Windows[0].Panes = []TaskId{"Pane-1"}
+	proj.Windows[1].Panes = []TaskId{"Pane-2", "Pane-3"}
+	s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+	s.Expect(session.GetWindowAndPaneNames(ctx)).To(HaveExactElements([]T{
+		{"Window-1", "Pane-1"},
+		{"Window-2", "Pane-2"},
+		{"Window-2", "Pane-3"},
+	}))
+	s.Expect(s.panesLeftToRight(ctx, session.MustGetWindows(ctx)[1])).To(Equal([]string{"Pane-2", "Pane-3"}))
+	s.Expect(session.MustGetWindows(ctx)[1].MustGetPanes(ctx).FindByTitle("Pane-2").Id).
+		To(Equal(moved.Id), "The pane keeps running")
+}
+
+func (s *ProjectEnsureStartedTestSuite) TestMoveTaskToNewWindow() {
+	ctx := context.Background()
+	proj := CreateProject()
+	proj.AppendNamedWindow("Window-1").
+		AppendPane(proj.CreatePaneWithCommands("Pane-1")).
+		AppendPane(proj.CreatePane("Pane-2", TaskExec("sleep 100")))
+	session := s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+	moved := session.MustGetWindows(ctx)[0].MustGetPanes(ctx).FindByTitle("Pane-2")
+
+	proj.Windows[0].Panes = []TaskId{"Pane-1"}
+	proj.AppendNamedWindow("Window-2").AppendPane("Pane-2")
+	s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+	s.Expect(session.GetWindowAndPaneNames(ctx)).To(HaveExactElements([]T{
+		{"Window-1", "Pane-1"},
+		{"Window-2", "Pane-2"},
+	}), "The pane created for the task in the new window is removed")
+	s.Expect(session.MustGetWindows(ctx)[1].MustGetPanes(ctx)[0].Id).
+		To(Equal(moved.Id), "The pane keeps running")
+}
+
+func (s *ProjectEnsureStartedTestSuite) TestRenameWindowWithStableId() {
+	ctx := context.Background()
+	proj := CreateProject()
+	proj.AppendNamedWindow("Editor").
+		AppendPane(proj.CreatePaneWithCommands("Pane-1"))
+	proj.AppendNamedWindow("Logs").
+		AppendPane(proj.CreatePaneWithCommands("Pane-2"))
+	session := s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+	windows := session.MustGetWindows(ctx)
+
+	// The id is stored on the running window first
+	proj.Windows[0].StableId = "editor"
+	s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+	proj.Windows[0].Name = "Code"
+	status, err := s.server.GetProjectStatus(ctx, proj.Project)
+	s.Expect(err).ToNot(HaveOccurred())
+	s.Expect(status.Windows[0].RenamedFrom).To(Equal("Editor"))
+	s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+
+	s.Expect(session.MustGetWindows(ctx)).To(HaveExactElements(
+		And(HaveField("Name", "Code"), HaveField("Id", windows[0].Id)),
+		HaveField("Id", windows[1].Id),
+	))
+	status, err = s.server.GetProjectStatus(ctx, proj.Project)
+	s.Expect(err).ToNot(HaveOccurred())
+	s.Expect(status.HasDrift()).To(BeFalse())
+}
+
+func (s *ProjectEnsureStartedTestSuite) TestDuplicateStableIdsIsAnError() {
+	ctx := context.Background()
+	proj := CreateProjectWithWindowNames("Window-1", "Window-2")
+	proj.Windows[0].StableId = "window"
+	proj.Windows[1].StableId = "window"
+	_, err := proj.EnsureStarted(ctx, s.server)
+	s.Expect(err).To(MatchError(ContainSubstring("Duplicate window id")))
+}
+
+// startConcurrently starts the projects at the same time, like `muxify up`
+func (s *ProjectEnsureStartedTestSuite) startConcurrently(
+	ctx context.Context,
+	projects ...*TestProject,
+) []TmuxSession {
+	sessions := make([]TmuxSession, len(projects))
+	errs := make([]error, len(projects))
+	var wg sync.WaitGroup
+	for i, proj := range projects {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sessions[i], errs[i] = proj.EnsureStarted(ctx, s.server)
+		}()
+	}
+	wg.Wait()
+	for i := range sessions {
+		sessions[i] = s.handleProjectStart(sessions[i], errs[i])
+	}
+	return sessions
+}
+
+func (s *ProjectEnsureStartedTestSuite) TestReconcileProjectsConcurrently() {
+	ctx := context.Background()
+	createProject := func(prefix string) *TestProject {
+		proj := CreateProject()
+		proj.AppendNamedWindow(prefix + "-1").
+			AppendPane(proj.CreatePaneWithCommands(prefix + "-a")).
+			AppendPane(proj.CreatePaneWithCommands(prefix + "-b"))
+		proj.AppendNamedWindow(prefix + "-2").
+			AppendPane(proj.CreatePaneWithCommands(prefix + "-c"))
+		return proj
+	}
+	projA, projB := createProject("A"), createProject("B")
+	sessions := s.startConcurrently(ctx, projA, projB)
+	s.Expect(sessions[0].GetWindowAndPaneNames(ctx)).To(HaveExactElements(
+		T{"A-1", "A-a"}, T{"A-1", "A-b"}, T{"A-2", "A-c"},
+	))
+	s.Expect(sessions[1].GetWindowAndPaneNames(ctx)).To(HaveExactElements(
+		T{"B-1", "B-a"}, T{"B-1", "B-b"}, T{"B-2", "B-c"},
+	))
+
+	s.Expect(sessions[1].MustGetWindows(ctx)[1].Select(ctx)).To(Succeed())
+	s.Expect(s.server.GetCurrentWindowIndexForSession(ctx, sessions[0])).To(Equal(0))
+	s.Expect(s.server.GetCurrentWindowIndexForSession(ctx, sessions[1])).To(Equal(1))
+
+	// Reordering windows moves them based on their index in their own session
+	for _, proj := range []*TestProject{projA, projB} {
+		proj.Windows[0], proj.Windows[1] = proj.Windows[1], proj.Windows[0]
+	}
+	sessions = s.startConcurrently(ctx, projA, projB)
+	s.Expect(sessions[0].GetWindowAndPaneNames(ctx)).To(HaveExactElements(
+		T{"A-2", "A-c"}, T{"A-1", "A-a"}, T{"A-1", "A-b"},
+	))
+	s.Expect(sessions[1].GetWindowAndPaneNames(ctx)).To(HaveExactElements(
+		T{"B-2", "B-c"}, T{"B-1", "B-a"}, T{"B-1", "B-b"},
+	))
+}
+
+func (s *ProjectEnsureStartedTestSuite) TestReportStepInProgressWhenCancelled() {
+	proj := CreateProjectWithWindowNames("Window-1")
+	ctx, cancel := context.WithCancelCause(context.Background())
+	cancel(errors.New("Timed out"))
+	_, err := proj.EnsureStarted(ctx, s.server)
+	s.Expect(err).To(MatchError("Timed out while starting the session"))
+
+	unlock, err := s.server.LockSession(context.Background(), proj.Name)
+	s.Expect(err).ToNot(HaveOccurred())
+	defer unlock()
+	ctx, cancelTimeout := context.WithTimeoutCause(
+		context.Background(), 100*time.Millisecond, errors.New("Timed out"))
+	defer cancelTimeout()
+	_, err = proj.EnsureStarted(ctx, s.server)
+	s.Expect(err).To(MatchError(
+		"Timed out while waiting for another muxify process starting the session"))
+}
+
+func (s *ProjectEnsureStartedTestSuite) TestStartSameProjectConcurrently() {
+	ctx := context.Background()
+	proj := CreateProject()
+	proj.AppendNamedWindow("Window-1").AppendPane(proj.CreatePaneWithCommands("Pane-1"))
+	proj.AppendNamedWindow("Window-2").AppendPane(proj.CreatePaneWithCommands("Pane-2"))
+	sessions := s.startConcurrently(ctx, proj, proj, proj)
+
+	s.Expect(sessions[1].Id).To(Equal(sessions[0].Id))
+	s.Expect(sessions[2].Id).To(Equal(sessions[0].Id))
+	s.Expect(sessions[0].GetWindowAndPaneNames(ctx)).To(HaveExactElements(
+		T{"Window-1", "Pane-1"}, T{"Window-2", "Pane-2"},
+	))
 }
 
 func (s *ProjectEnsureStartedTestSuite) TestExecuteCommandsInConfiguration() {
+	ctx := context.Background()
 	proj := CreateProject()
 	proj.AppendNamedWindow("Window-1").
 		AppendPane(proj.CreatePaneWithCommands("Pane-1", "echo \"Foo\"")).
 		AppendPane(proj.CreatePaneWithCommands("Pane-2", "echo \"Bar\""))
-	session := s.handleProjectStart(proj.EnsureStarted(s.server))
-	cm := MustStartControlMode(s.server, session)
+	session := s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+	cm := MustStartControlMode(ctx, s.server, session)
 	defer cm.MustClose()
 	outputEvents := s.getOutputLinesFromEvents(s.getOutputEvents(GetLines(cm.stdout)))
-	panes, err := session.GetPanes()
+	panes, err := session.GetPanes(ctx)
 	s.Expect(err).ToNot(HaveOccurred())
-	panes[0].MustRunShellCommand("echo \"DONE 1\"")
+	panes[0].MustRunShellCommand(ctx, "echo \"DONE 1\"")
 	s.Eventually(outputEvents).Should(Receive(Equal("DONE 1")))
-	panes[1].MustRunShellCommand("echo \"DONE 2\"")
+	panes[1].MustRunShellCommand(ctx, "echo \"DONE 2\"")
 	s.Eventually(outputEvents).Should(Receive(Equal("DONE 2")))
-	output1 := s.server.Command("capture-pane", "-p", "-t", panes[0].Id).MustOutput()
-	output2 := s.server.Command("capture-pane", "-p", "-t", panes[1].Id).MustOutput()
+	output1 := s.server.Command(ctx, "capture-pane", "-p", "-t", panes[0].Id).MustOutput()
+	output2 := s.server.Command(ctx, "capture-pane", "-p", "-t", panes[1].Id).MustOutput()
 	s.Expect(output1).To(MatchRegexp("(?m:^Foo$)"))
 	s.Expect(output2).To(MatchRegexp("(?m:^Bar$)"))
 }
 
 func (s *ProjectEnsureStartedTestSuite) TestVerifyFirstPanesDoesntRunTwiceOnRestart() {
+	ctx := context.Background()
 	proj := CreateProject()
 	proj.AppendNamedWindow("Window-1").
 		AppendPane(proj.CreatePaneWithCommands("Pane-1", "echo \"Foo\"")).
 		AppendPane(proj.CreatePaneWithCommands("Pane-2", "echo \"Bar\""))
-	session := s.handleProjectStart(proj.EnsureStarted(s.server))
-	cm := MustStartControlMode(s.server, session)
+	session := s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+	cm := MustStartControlMode(ctx, s.server, session)
 	defer cm.MustClose()
 	outputEvents := s.getOutputLinesFromEvents(s.getOutputEvents(GetLines(cm.stdout)))
-	panes, err := session.GetPanes()
+	panes, err := session.GetPanes(ctx)
 	s.Expect(err).ToNot(HaveOccurred())
 
 	// Wait for the commands to have executed
-	panes[0].MustRunShellCommand("echo \"DONE 1\"")
+	panes[0].MustRunShellCommand(ctx, "echo \"DONE 1\"")
 	s.Eventually(outputEvents).Should(Receive(Equal("DONE 1")))
 
 	// Start this again
-	s.handleProjectStart(proj.EnsureStarted(s.server))
+	s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
 
 	// Wait for all commands to have executed
-	panes[0].MustRunShellCommand("echo \"DONE 1\"")
+	panes[0].MustRunShellCommand(ctx, "echo \"DONE 1\"")
 	s.Eventually(outputEvents).Should(Receive(Equal("DONE 1")))
 
-	panes, err = session.GetPanes()
+	panes, err = session.GetPanes(ctx)
 	s.Expect(err).ToNot(HaveOccurred())
 
-	output1 := s.server.Command("capture-pane", "-p", "-t", panes[0].Id).MustOutput()
-	output2 := s.server.Command("capture-pane", "-p", "-t", panes[1].Id).MustOutput()
+	output1 := s.server.Command(ctx, "capture-pane", "-p", "-t", panes[0].Id).MustOutput()
+	output2 := s.server.Command(ctx, "capture-pane", "-p", "-t", panes[1].Id).MustOutput()
 
 	var exp *regexp.Regexp = regexp.MustCompile(`(?m:^(?:Foo|Bar))`)
 	s.Expect(exp.FindAllString(string(output1), -1)).To(Equal([]string{"Foo"}))
 	s.Expect(exp.FindAllString(string(output2), -1)).To(Equal([]string{"Bar"}))
 }
 
+func (s *ProjectEnsureStartedTestSuite) TestForEachAddsAndPrunesWindows() {
+	ctx := context.Background()
+	packages := path.Join(s.dir, "packages")
+	defer os.RemoveAll(packages)
+	s.Expect(os.MkdirAll(path.Join(packages, "a"), 0700)).To(Succeed())
+	s.Expect(os.MkdirAll(path.Join(packages, "b"), 0700)).To(Succeed())
+	proj := CreateProjectWithWindowNames("Window-1")
+	proj.WorkingDirectory = s.dir
+	proj.Windows = append(proj.Windows, Window{
+		ForEach: &ForEach{Glob: "packages/*"},
+		Prune:   true,
+	})
+	session := s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+	s.Expect(session.GetWindows(ctx)).To(HaveExactElements(
+		HaveField("Name", "Window-1"),
+		HaveField("Name", "a"),
+		HaveField("Name", "b"),
+	))
+
+	s.Expect(os.Remove(path.Join(packages, "a"))).To(Succeed())
+	s.Expect(os.MkdirAll(path.Join(packages, "c"), 0700)).To(Succeed())
+	s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+	s.Expect(session.GetWindows(ctx)).To(HaveExactElements(
+		HaveField("Name", "Window-1"),
+		HaveField("Name", "b"),
+		HaveField("Name", "c"),
+	))
+}
+
+func (s *ProjectEnsureStartedTestSuite) TestForEachPruningKeepsOtherWindows() {
+	ctx := context.Background()
+	packages := path.Join(s.dir, "packages")
+	defer os.RemoveAll(packages)
+	s.Expect(os.MkdirAll(path.Join(packages, "a"), 0700)).To(Succeed())
+	proj := CreateProjectWithWindowNames("Window-1")
+	proj.WorkingDirectory = s.dir
+	proj.Windows = append(proj.Windows, Window{
+		ForEach: &ForEach{Glob: "packages/*"},
+		Prune:   true,
+	})
+	session := s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+	windows := session.MustGetWindows(ctx)
+	_, err := s.server.CreateWindow(ctx, AfterWindow(&windows[len(windows)-1]), "scratch", "")
+	s.Expect(err).ToNot(HaveOccurred())
+
+	s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+	s.Expect(session.GetWindows(ctx)).To(HaveExactElements(
+		HaveField("Name", "Window-1"),
+		HaveField("Name", "a"),
+		HaveField("Name", "scratch"),
+	))
+}
+
+func (s *ProjectEnsureStartedTestSuite) TestForEachWithoutPruningKeepsWindows() {
+	ctx := context.Background()
+	packages := path.Join(s.dir, "packages")
+	defer os.RemoveAll(packages)
+	s.Expect(os.MkdirAll(path.Join(packages, "a"), 0700)).To(Succeed())
+	proj := CreateProjectWithWindowNames("Window-1")
+	proj.WorkingDirectory = s.dir
+	proj.Windows = append(proj.Windows, Window{ForEach: &ForEach{Glob: "packages/*"}})
+	session := s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+
+	s.Expect(os.Remove(path.Join(packages, "a"))).To(Succeed())
+	s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+	s.Expect(session.GetWindows(ctx)).To(HaveExactElements(
+		HaveField("Name", "Window-1"),
+		HaveField("Name", "a"),
+	))
+}
+
 func BeStarted() types.GomegaMatcher {
 	return HaveField("Id", MatchRegexp("^\\$\\d+"))
 }
 
+func (s *ProjectEnsureStartedTestSuite) TestRestoreSavedScrollback() {
+	ctx := context.Background()
+	proj := CreateProject()
+	editor := proj.CreatePaneWithCommands("editor", "echo saved-$((40 + 2))")
+	proj.AppendNamedWindow("Window-1").AppendPane(editor)
+	session, err := proj.EnsureStarted(ctx, s.server)
+	s.Expect(err).ToNot(HaveOccurred())
+	s.Eventually(s.paneContent(ctx, session, editor)).Should(ContainSubstring("saved-42"))
+
+	saved, err := CaptureSession(ctx, s.server, proj.Project)
+	s.Expect(err).ToNot(HaveOccurred())
+	stateDir := s.T().TempDir()
+	s.Expect(WriteSavedSession(stateDir, proj.Name, saved)).To(Succeed())
+	proj.Saved, err = ReadSavedSession(os.DirFS(stateDir), proj.Name)
+	s.Expect(err).ToNot(HaveOccurred())
+
+	savedName := proj.Name
+	proj.Name = CreateRandomName()
+	proj.Tasks[editor] = Task{Commands: Commands{"echo restored-$((40 + 2))"}}
+	restored := s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+	s.Expect(s.server.KillSession(ctx, session)).To(Succeed())
+	s.Eventually(s.paneContent(ctx, restored, editor)).Should(ContainSubstring("restored-42"))
+	content := s.paneContent(ctx, restored, editor)()
+	s.Expect(content).To(ContainSubstring("saved-42"))
+	s.Expect(content).ToNot(ContainSubstring(stateDir), "Nothing is typed to restore")
+
+	s.Expect(proj.Saved.Windows[0].Panes[0].HistoryFile).ToNot(BeAnExistingFile())
+	replayed, err := ReadSavedSession(os.DirFS(stateDir), savedName)
+	s.Expect(err).ToNot(HaveOccurred())
+	s.Expect(replayed.Windows[0].Panes).To(BeEmpty(), "The history is only replayed once")
+}
+
+func (s *ProjectEnsureStartedTestSuite) TestRestoreSavedWorkingDir() {
+	ctx := context.Background()
+	savedDir := s.T().TempDir()
+	proj := CreateProject()
+	editor := proj.CreatePane("editor")
+	proj.AppendNamedWindow("Window-1").AppendPane(editor)
+	proj.Saved = &SavedSession{Windows: []SavedWindow{{Name: "Window-1", Panes: []SavedPane{
+		{Task: editor, Path: savedDir, HistoryFile: path.Join(savedDir, "missing.txt")},
+	}}}}
+	session := s.handleProjectStart(proj.EnsureStarted(ctx, s.server))
+	pane := session.MustGetWindows(ctx)[0].MustGetPanes(ctx).FindByTitle(editor)
+	s.Expect(pane).ToNot(BeNil())
+	s.Eventually(pane.CurrentPath).WithContext(ctx).Should(Equal(savedDir))
+}
+
+func (s *ProjectTestSuite) paneContent(
+	ctx context.Context,
+	session TmuxSession,
+	title string,
+) func() string {
+	return func() string {
+		pane := session.MustGetWindows(ctx)[0].MustGetPanes(ctx).FindByTitle(title)
+		if pane == nil {
+			return ""
+		}
+		history, _ := pane.CaptureHistory(ctx)
+		return history
+	}
+}
+
 func (s *ProjectTestSuite) getOutputEvents(lines <-chan string) <-chan TmuxOutputEvent {
 
 	c := make(chan TmuxOutputEvent)
@@ -533,3 +1397,38 @@ func (s *ProjectEnsureStartedTestSuite) handleProjectStart(
 	s.knownSessions = append(s.knownSessions, session)
 	return session
 }
+
+func BenchmarkStartProjectWith10Windows(b *testing.B) {
+	ctx := context.Background()
+	server := MustCreateTestServer()
+	defer server.KillServer(ctx)
+	if _, err := server.StartSessionByName(ctx, "keep server running"); err != nil {
+		b.Fatal(err)
+	}
+	project := Project{Tasks: map[string]Task{}}
+	for i := range 10 {
+		editor, shell := fmt.Sprintf("editor-%d", i), fmt.Sprintf("shell-%d", i)
+		project.Windows = append(project.Windows, NewWindow(fmt.Sprintf("Window %d", i), editor, shell))
+		project.Tasks[editor] = Task{Commands: Commands{"echo editor"}}
+		project.Tasks[shell] = Task{Commands: Commands{"echo shell", "echo ready"}}
+	}
+	benchmark := func(server TmuxServer) func(b *testing.B) {
+		return func(b *testing.B) {
+			for range b.N {
+				project.Name = CreateRandomProjectName()
+				session, err := project.EnsureStarted(ctx, server)
+				if err != nil {
+					b.Fatal(err)
+				}
+				b.StopTimer()
+				server.KillSession(ctx, session)
+				b.StartTimer()
+			}
+		}
+	}
+	b.Run("unbatched", func(b *testing.B) {
+		useUnbatchedTmux(b)
+		benchmark(server)(b)
+	})
+	b.Run("batched", benchmark(server))
+}
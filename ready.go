@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// readyQuietPeriod is how long the output of a pane must be unchanged before
+	// its shell is considered ready for input, when the task has no prompt.
+	readyQuietPeriod  = 100 * time.Millisecond
+	readyPollInterval = 25 * time.Millisecond
+	// readyTimeout is how long to wait for panes to be ready, before typing the
+	// input anyway.
+	readyTimeout = 10 * time.Second
+	// paneSeparator separates the contents of panes captured in a single tmux
+	// invocation
+	paneSeparator = "\x1e"
+)
+
+// paneInput collects the keystrokes typed into new panes, to type them once
+// the shells in the panes are ready for input. Shells with slow startup
+// files would otherwise drop, or garble, keystrokes arriving before the
+// prompt.
+type paneInput struct {
+	server TmuxServer
+	panes  []*paneReadiness
+}
+
+type paneReadiness struct {
+	pane   TmuxPane
+	prompt *regexp.Regexp
+	// input are the commands typing into the pane
+	input [][]string
+	// content is the last captured content of the pane, and changed when it
+	// last changed.
+	content string
+	changed time.Time
+	// dead is whether the pane's process has exited, and closed whether the
+	// pane no longer exists, so it never gets ready.
+	dead   bool
+	closed bool
+}
+
+func (s TmuxServer) paneInput() *paneInput {
+	return &paneInput{server: s}
+}
+
+// add adds a command typing input into the pane, once the pane is ready for
+// the task.
+func (i *paneInput) add(pane TmuxPane, task Task, arg ...string) {
+	index := slices.IndexFunc(i.panes, func(r *paneReadiness) bool { return r.pane.Id == pane.Id })
+	if index < 0 {
+		index = len(i.panes)
+		i.panes = append(i.panes, &paneReadiness{pane: pane, prompt: task.promptPattern})
+	}
+	i.panes[index].input = append(i.panes[index].input, arg)
+}
+
+// send waits until the panes are ready, and types the input into the panes
+// not closed in the meantime.
+func (i *paneInput) send(ctx context.Context) error {
+	if err := i.waitUntilReady(ctx); err != nil {
+		return err
+	}
+	batch := i.server.Batch()
+	for _, r := range i.panes {
+		if r.closed {
+			continue
+		}
+		for _, arg := range r.input {
+			batch.Add(arg...)
+		}
+	}
+	i.panes = nil
+	return batch.Run(ctx)
+}
+
+// waitUntilReady waits until the last line of each pane matches the prompt of
+// its task, or, without a prompt, until the pane shows output which has been
+// unchanged for readyQuietPeriod. Panes not ready after readyTimeout are
+// considered ready, logging a warning. Dead and closed panes are not waited
+// for.
+func (i *paneInput) waitUntilReady(ctx context.Context) error {
+	deadline := time.Now().Add(readyTimeout)
+	pending := slices.Clone(i.panes)
+	for len(pending) > 0 {
+		if err := i.capture(ctx, pending); err != nil {
+			// Capturing fails when a pane was closed
+			if pending, err = i.dropClosed(ctx, pending, err); err != nil {
+				return err
+			}
+			continue
+		}
+		now := time.Now()
+		pending = slices.DeleteFunc(pending, func(r *paneReadiness) bool { return r.dead || r.ready(now) })
+		if len(pending) == 0 {
+			break
+		}
+		if now.After(deadline) {
+			slog.Warn("Typing into panes not ready for input", "panes", len(pending))
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		case <-time.After(readyPollInterval):
+		}
+	}
+	return nil
+}
+
+func (r paneReadiness) ready(now time.Time) bool {
+	if r.prompt != nil {
+		return r.prompt.MatchString(lastLine(r.content))
+	}
+	return r.content != "" && now.Sub(r.changed) >= readyQuietPeriod
+}
+
+// capture captures the visible contents of the panes, and whether they are
+// dead, in a single tmux invocation.
+func (i *paneInput) capture(ctx context.Context, panes []*paneReadiness) error {
+	batch := i.server.Batch()
+	for _, r := range panes {
+		batch.Add("capture-pane", "-p", "-t", r.pane.Id)
+		batch.Add("display-message", "-p", "-t", r.pane.Id, "#{pane_dead}"+paneSeparator)
+	}
+	output, err := i.server.formatCommand(ctx, batch.args()...).Output()
+	if err != nil {
+		return err
+	}
+	contents := strings.Split(string(output), paneSeparator+"\n")
+	if len(contents) != len(panes)+1 {
+		return fmt.Errorf("Unexpected output capturing %d panes", len(panes))
+	}
+	now := time.Now()
+	for j, r := range panes {
+		content, dead := strings.CutSuffix(contents[j], "1")
+		if !dead {
+			content = strings.TrimSuffix(content, "0")
+		}
+		content = strings.TrimRight(content, "\n")
+		if content != r.content {
+			r.content = content
+			r.changed = now
+		}
+		r.dead = dead
+	}
+	return nil
+}
+
+// dropClosed marks the panes no longer existing as closed, and returns the
+// remaining panes. The capture error is returned if no pane was closed.
+func (i *paneInput) dropClosed(
+	ctx context.Context,
+	panes []*paneReadiness,
+	captureErr error,
+) ([]*paneReadiness, error) {
+	records, err := i.server.query(ctx, tmuxFormat{field("pane_id")}, "list-panes", "-a")
+	if err != nil {
+		return nil, errors.Join(captureErr, err)
+	}
+	remaining := slices.DeleteFunc(slices.Clone(panes), func(r *paneReadiness) bool {
+		r.closed = !slices.ContainsFunc(records, func(fields []string) bool { return fields[0] == r.pane.Id })
+		if r.closed {
+			slog.Debug("Not typing into closed pane", "pane", r.pane.Id)
+		}
+		return r.closed
+	})
+	if len(remaining) == len(panes) {
+		return nil, captureErr
+	}
+	return remaining, nil
+}
+
+// lastLine returns the last non-empty line of the pane's content
+func lastLine(content string) string {
+	content = strings.TrimRight(content, "\n")
+	return content[strings.LastIndex(content, "\n")+1:]
+}
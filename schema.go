@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// JSONSchema generates a JSON Schema for the configuration file, derived from
+// the YAML annotations of the configuration types, allowing editors to provide
+// completion and validation for all configuration formats.
+func JSONSchema() map[string]any {
+	defs := make(map[string]any)
+	schema := schemaForType(reflect.TypeOf(MuxifyConfiguration{}), defs)
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["$id"] = "https://raw.githubusercontent.com/stroiman/muxify/main/schema.json"
+	schema["title"] = "Muxify configuration"
+	schema["$defs"] = defs
+	return schema
+}
+
+func WriteJSONSchema(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(JSONSchema())
+}
+
+// schemaForType returns the schema for a type. Struct types are added to defs,
+// and referenced by name.
+func schemaForType(t reflect.Type, defs map[string]any) map[string]any {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return schemaForType(t.Elem(), defs)
+	case reflect.String:
+		if t == reflect.TypeOf(RestartPolicy("")) {
+			return map[string]any{"type": "string", "enum": restartPolicies}
+		}
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": schemaForType(t.Elem(), defs)}
+	case reflect.Map:
+		return map[string]any{
+			"type":                 "object",
+			"additionalProperties": schemaForType(t.Elem(), defs),
+		}
+	case reflect.Struct:
+		if t == reflect.TypeOf(MuxifyConfiguration{}) {
+			return structSchema(t, defs)
+		}
+		if _, ok := defs[t.Name()]; !ok {
+			defs[t.Name()] = nil // Prevent infinite recursion
+			defs[t.Name()] = structSchema(t, defs)
+		}
+		return map[string]any{"$ref": "#/$defs/" + t.Name()}
+	}
+	return map[string]any{}
+}
+
+func structSchema(t reflect.Type, defs map[string]any) map[string]any {
+	properties := make(map[string]any)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		properties[name] = schemaForType(field.Type, defs)
+	}
+	schema := map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if t == reflect.TypeOf(ForEach{}) {
+		// A for_each can also be written as just the glob.
+		return map[string]any{"oneOf": []any{map[string]any{"type": "string"}, schema}}
+	}
+	if t == reflect.TypeOf(Task{}) {
+		// A task with no properties is written as `task:`, i.e., null.
+		return map[string]any{"oneOf": []any{map[string]any{"type": "null"}, schema}}
+	}
+	return schema
+}
@@ -0,0 +1,37 @@
+package main_test
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/suite"
+
+	. "github.com/stroiman/muxify"
+)
+
+type SchemaTestSuite struct {
+	GomegaSuite
+}
+
+func TestSchema(t *testing.T) {
+	suite.Run(t, new(SchemaTestSuite))
+}
+
+func (s *SchemaTestSuite) TestPublishedSchemaIsUpToDate() {
+	var schema bytes.Buffer
+	s.Expect(WriteJSONSchema(&schema)).To(Succeed())
+	published, err := os.ReadFile("schema.json")
+	s.Expect(err).ToNot(HaveOccurred())
+	s.Expect(string(published)).To(
+		Equal(schema.String()), "Run `go run . schema > schema.json` to update")
+}
+
+func (s *SchemaTestSuite) TestPropertiesUseConfigurationNames() {
+	defs := JSONSchema()["$defs"].(map[string]any)
+	project := defs["Project"].(map[string]any)["properties"].(map[string]any)
+	s.Expect(project).To(HaveKey("working_dir"))
+	s.Expect(project).To(HaveKey("windows"))
+	s.Expect(project).ToNot(HaveKey("profile"))
+}
@@ -1,13 +1,79 @@
 package main_test
 
 import (
+	"fmt"
 	"math/rand"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
 
 	. "github.com/stroiman/muxify"
 )
 
+// realTmuxEnv is the environment variable holding the path of tmux, when the
+// test binary runs as tmux
+const realTmuxEnv = "MUXIFY_TEST_REAL_TMUX"
+
+func TestMain(m *testing.M) {
+	if filepath.Base(os.Args[0]) == "tmux" {
+		os.Exit(runUnbatchedTmux(os.Args[1:]))
+	}
+	os.Exit(m.Run())
+}
+
+// useUnbatchedTmux runs the commands of a TmuxBatch in a tmux invocation each,
+// to measure the effect of batching. A "tmux" link to the test binary is put
+// first in PATH, which splits the batch before running the real tmux.
+func useUnbatchedTmux(tb testing.TB) {
+	realTmux, err := exec.LookPath("tmux")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	dir := tb.TempDir()
+	if err = os.Symlink(executable, filepath.Join(dir, "tmux")); err != nil {
+		tb.Fatal(err)
+	}
+	tb.Setenv(realTmuxEnv, realTmux)
+	tb.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+// runUnbatchedTmux runs each of the commands chained with ";" in a tmux
+// invocation of its own, stopping at the first failing command like tmux.
+func runUnbatchedTmux(args []string) int {
+	i := 0
+	for i < len(args) && strings.HasPrefix(args[i], "-") {
+		if slices.Contains([]string{"-L", "-S", "-f"}, args[i]) {
+			i++
+		}
+		i++
+	}
+	flags, commands := args[:i], args[i:]
+	for len(commands) > 0 {
+		command := commands
+		commands = nil
+		if j := slices.Index(command, ";"); j >= 0 {
+			command, commands = command[:j], command[j+1:]
+		}
+		cmd := exec.Command(os.Getenv(realTmuxEnv), append(slices.Clone(flags), command...)...)
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+		if err := cmd.Run(); err != nil {
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				return exitErr.ExitCode()
+			}
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	return 0
+}
+
 var letterRunes = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
 
 func randStringRunes(n int) string {
@@ -36,5 +102,7 @@ func MustCreateTestServer() TmuxServer {
 	return TmuxServer{
 		SocketName: socketName,
 		ConfigFile: configFile,
+		// Don't let hooks and key bindings run the test binary
+		Executable: "true",
 	}
 }
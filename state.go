@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"strings"
+)
+
+const savedSessionFile = "session.json"
+
+// SavedSession is the state of a project's session, saved by `muxify save`, and
+// restored when panes are recreated, e.g., after a reboot.
+type SavedSession struct {
+	Windows []SavedWindow `json:"windows"`
+}
+
+type SavedWindow struct {
+	Name   string      `json:"name"`
+	Layout string      `json:"layout"`
+	Panes  []SavedPane `json:"panes"`
+}
+
+type SavedPane struct {
+	Task TaskId `json:"task"`
+	Path string `json:"path"`
+	// HistoryFile is the file containing the scrollback of the pane
+	HistoryFile string `json:"history_file"`
+	// History is the captured scrollback, not part of the JSON file itself
+	History string `json:"-"`
+}
+
+func (s *SavedSession) findWindow(name string) (SavedWindow, bool) {
+	if s != nil {
+		for _, w := range s.Windows {
+			if w.Name == name {
+				return w, true
+			}
+		}
+	}
+	return SavedWindow{}, false
+}
+
+func (s *SavedSession) findPane(windowName string, task TaskId) (SavedPane, bool) {
+	w, _ := s.findWindow(windowName)
+	for _, p := range w.Panes {
+		if p.Task == task {
+			return p, true
+		}
+	}
+	return SavedPane{}, false
+}
+
+// CaptureSession captures the scrollback, current path, and layout of all
+// panes running a task in the project's session.
+func CaptureSession(ctx context.Context, server TmuxServer, p Project) (result SavedSession, err error) {
+	sessions, err := server.GetRunningSessions(ctx)
+	if err != nil {
+		return
+	}
+	session, ok := TmuxSessions(sessions).FindByName(p.Name)
+	if !ok {
+		return result, fmt.Errorf("Project %q is not running", p.Name)
+	}
+	windows, err := session.GetWindows(ctx)
+	if err != nil {
+		return
+	}
+	for _, window := range windows {
+		saved := SavedWindow{Name: window.Name}
+		if saved.Layout, err = window.WindowLayout(ctx); err != nil {
+			return
+		}
+		var panes TmuxPanes
+		if panes, err = window.GetPanes(ctx); err != nil {
+			return
+		}
+		for _, pane := range panes {
+			if _, isTask := p.Tasks[pane.Title]; !isTask {
+				continue
+			}
+			savedPane := SavedPane{Task: pane.Title}
+			if savedPane.Path, err = pane.CurrentPath(ctx); err != nil {
+				return
+			}
+			if savedPane.History, err = pane.CaptureHistory(ctx); err != nil {
+				return
+			}
+			saved.Panes = append(saved.Panes, savedPane)
+		}
+		if len(saved.Panes) > 0 {
+			result.Windows = append(result.Windows, saved)
+		}
+	}
+	return
+}
+
+// projectStateDirName encodes the project name as the name of the directory in
+// the state dir, where the project's state is saved. `/` and `%` are escaped,
+// and a leading `.`, so the directory is always inside the state dir.
+func projectStateDirName(projectName string) string {
+	name := strings.NewReplacer("%", "%25", "/", "%2F", "\x00", "%00").Replace(projectName)
+	if name == "" || strings.HasPrefix(name, ".") {
+		name = "%2E" + strings.TrimPrefix(name, ".")
+	}
+	return name
+}
+
+// WriteSavedSession writes the session state of the project to the state dir,
+// replacing any previously saved state. The history of each pane is written to
+// a separate file.
+func WriteSavedSession(stateDir string, projectName string, session SavedSession) error {
+	dir := path.Join(stateDir, projectStateDirName(projectName))
+	if path.Dir(dir) != path.Clean(stateDir) {
+		return fmt.Errorf("Project %q: Invalid state dir %s", projectName, dir)
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	for wi, window := range session.Windows {
+		for pi, pane := range window.Panes {
+			historyFile := path.Join(dir, fmt.Sprintf("history-%d-%d.txt", wi, pi))
+			if err := os.WriteFile(historyFile, []byte(pane.History+"\n"), 0600); err != nil {
+				return err
+			}
+			session.Windows[wi].Panes[pi].HistoryFile = historyFile
+		}
+	}
+	data, err := json.MarshalIndent(session, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path.Join(dir, savedSessionFile), data, 0600)
+}
+
+// ReadSavedSession reads the session state of the project saved in the state
+// dir, returning nil if no state has been saved. Panes whose history has
+// already been replayed are left out, so recreating them starts afresh.
+func ReadSavedSession(stateDir fs.FS, projectName string) (*SavedSession, error) {
+	dir := projectStateDirName(projectName)
+	data, err := fs.ReadFile(stateDir, path.Join(dir, savedSessionFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var session SavedSession
+	if err = json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("%s: %w", savedSessionFile, err)
+	}
+	for i, window := range session.Windows {
+		session.Windows[i].Panes = slices.DeleteFunc(window.Panes, func(pane SavedPane) bool {
+			if pane.HistoryFile == "" {
+				return false
+			}
+			_, err := fs.Stat(stateDir, path.Join(dir, path.Base(pane.HistoryFile)))
+			return errors.Is(err, fs.ErrNotExist)
+		})
+	}
+	return &session, nil
+}
+
+// replayCommand returns the pane command writing the saved history to the
+// pane, before replacing itself with the shell. Nothing is typed into the
+// shell, keeping its history clean. The history file is removed once written,
+// so the history is only replayed the first time the pane is recreated.
+func (saved SavedPane) replayCommand(shell string) string {
+	file := shellQuote(saved.HistoryFile)
+	return "cat " + file + " 2>/dev/null; rm -f " + file + "; exec " + shell
+}
+
+// defaultCommand returns the command tmux runs in a new pane, i.e., the
+// default-command run by the default-shell, or the default-shell as a login
+// shell.
+func (p TmuxPane) defaultCommand(ctx context.Context) (string, error) {
+	fields, err := p.displayFormat(ctx, tmuxFormat{encodedField("default-command"), field("default-shell")})
+	if err != nil {
+		return "", err
+	}
+	if fields[0] != "" {
+		return shellQuote(fields[1]) + " -c " + shellQuote(fields[0]), nil
+	}
+	return shellQuote(fields[1]) + " -l", nil
+}
+
+// restoreDir returns the saved working directory if it still exists, unless
+// the task has a working dir.
+func (saved SavedPane) restoreDir(task Task, dir string) string {
+	if task.WorkingDirectory != "" || saved.Path == "" {
+		return dir
+	}
+	if info, err := os.Stat(saved.Path); err != nil || !info.IsDir() {
+		return dir
+	}
+	return saved.Path
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func getStateDirPath(os OS) (string, error) {
+	if stateDir, found := os.LookupEnv("XDG_STATE_HOME"); found {
+		return path.Join(stateDir, getAppName(os)), nil
+	}
+	if homeDir, found := os.LookupEnv("HOME"); found {
+		return path.Join(homeDir, ".local", "state", getAppName(os)), nil
+	}
+	return "", errors.New("Home dir not configured")
+}
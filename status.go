@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ErrDrift is returned by the status command, when a running session doesn't
+// match the configuration.
+var ErrDrift = errors.New("The running sessions have drifted from the configuration")
+
+// commandsOption is the pane option recording the commands the pane was started
+// with, to detect changed commands.
+const commandsOption = "@muxify_commands"
+
+// commandsSignature encodes commands as a single line option value
+func commandsSignature(commands Commands) string {
+	return strconv.Quote(strings.Join(commands, "\n"))
+}
+
+type ProjectStatus struct {
+	Name    string         `json:"name"`
+	Running bool           `json:"running"`
+	Windows []WindowStatus `json:"windows,omitempty"`
+}
+
+type WindowStatus struct {
+	Name       string `json:"name"`
+	Missing    bool   `json:"missing,omitempty"`
+	OutOfOrder bool   `json:"out_of_order,omitempty"`
+	// RenamedFrom is the name of the running window, if the window was renamed
+	// in the configuration
+	RenamedFrom string       `json:"renamed_from,omitempty"`
+	Panes       []PaneStatus `json:"panes,omitempty"`
+}
+
+type PaneStatus struct {
+	Task    TaskId `json:"task"`
+	Missing bool   `json:"missing,omitempty"`
+	// Extra is a pane in a configured window, not running a configured task
+	Extra           bool   `json:"extra,omitempty"`
+	CommandsChanged bool   `json:"commands_changed,omitempty"`
+	Exited          bool   `json:"exited,omitempty"`
+	Command         string `json:"command,omitempty"`
+	Path            string `json:"path,omitempty"`
+	Restarts        int    `json:"restarts,omitempty"`
+	ExitStatus      *int   `json:"exit_status,omitempty"`
+}
+
+func (s PaneStatus) HasDrift() bool {
+	return s.Missing || s.Extra || s.CommandsChanged || s.Exited
+}
+
+func (s WindowStatus) HasDrift() bool {
+	return s.Missing || s.OutOfOrder || s.RenamedFrom != "" ||
+		slices.ContainsFunc(s.Panes, PaneStatus.HasDrift)
+}
+
+// HasDrift returns whether the running session differs from the configuration.
+// A project that isn't running has no drift.
+func (s ProjectStatus) HasDrift() bool {
+	return slices.ContainsFunc(s.Windows, WindowStatus.HasDrift)
+}
+
+// GetProjectStatus compares the project's running session to the
+// configuration.
+func (s TmuxServer) GetProjectStatus(ctx context.Context, p Project) (status ProjectStatus, err error) {
+	status.Name = p.Name
+	if p, err = p.Plan(); err != nil {
+		return
+	}
+	sessions, err := s.GetRunningSessions(ctx)
+	if err != nil {
+		return
+	}
+	session, running := TmuxSessions(sessions).FindByName(p.Name)
+	if !running {
+		return
+	}
+	status.Running = true
+	tmuxWindows, err := session.GetWindows(ctx)
+	if err != nil {
+		return
+	}
+	lastIndex := -1
+	for _, w := range p.Windows {
+		windowStatus := WindowStatus{Name: w.Name}
+		tmuxWindow, found := w.findIn(tmuxWindows)
+		index := slices.IndexFunc(tmuxWindows, func(t TmuxWindow) bool { return t.Id == tmuxWindow.Id })
+		if !found {
+			windowStatus.Missing = true
+			for _, taskId := range w.Panes {
+				windowStatus.Panes = append(windowStatus.Panes, PaneStatus{Task: taskId, Missing: true})
+			}
+		} else {
+			windowStatus.OutOfOrder = index < lastIndex
+			if tmuxWindow.Name != w.Name {
+				windowStatus.RenamedFrom = tmuxWindow.Name
+			}
+			lastIndex = max(index, lastIndex)
+			if windowStatus.Panes, err = p.paneStatuses(ctx, tmuxWindow, w); err != nil {
+				return
+			}
+		}
+		status.Windows = append(status.Windows, windowStatus)
+	}
+	return
+}
+
+func (p Project) paneStatuses(ctx context.Context, window TmuxWindow, w Window) ([]PaneStatus, error) {
+	panes, err := window.query(ctx, tmuxFormat{
+		field("pane_title"),
+		field("pane_current_command"),
+		field("pane_current_path"),
+		field("pane_dead"),
+		field(commandsOption),
+		field(restartPolicyOption),
+		field(restartsOption),
+		field(exitStatusOption),
+	}, "list-panes", "-t", window.Id)
+	if err != nil {
+		return nil, err
+	}
+	running := make(map[TaskId]PaneStatus)
+	var extra []PaneStatus
+	for _, fields := range panes {
+		status := PaneStatus{Task: fields[0], Command: fields[1], Path: fields[2]}
+		task, configured := p.Tasks[status.Task]
+		if !configured || !slices.Contains(w.Panes, status.Task) {
+			status.Extra = true
+			extra = append(extra, status)
+			continue
+		}
+		status.CommandsChanged = fields[4] != "" && fields[4] != task.signature()
+		// Only panes kept open after their program exits, e.g., supervised
+		// tasks, can be seen to exit. The commands of other tasks are typed
+		// into a shell, which may well be back at the prompt.
+		status.Exited = fields[3] == "1"
+		status.Restarts, _ = strconv.Atoi(fields[6])
+		if exitStatus, err := strconv.Atoi(fields[7]); err == nil {
+			status.ExitStatus = &exitStatus
+		}
+		running[status.Task] = status
+	}
+	result := make([]PaneStatus, 0, len(w.Panes)+len(extra))
+	for _, taskId := range w.Panes {
+		status, ok := running[taskId]
+		if !ok {
+			status = PaneStatus{Task: taskId, Missing: true}
+		}
+		result = append(result, status)
+	}
+	return append(result, extra...), nil
+}
+
+// WriteStatus writes the status of the projects as a tree
+func WriteStatus(w io.Writer, statuses []ProjectStatus) error {
+	var b strings.Builder
+	for _, project := range statuses {
+		if !project.Running {
+			fmt.Fprintf(&b, "%s: not running\n", project.Name)
+			continue
+		}
+		fmt.Fprintf(&b, "%s\n", project.Name)
+		for _, window := range project.Windows {
+			fmt.Fprintf(&b, "  %s%s\n", window.Name, parenthesize(window.problems()))
+			for _, pane := range window.Panes {
+				fmt.Fprintf(&b, "    %s%s", pane.Task, parenthesize(pane.problems()))
+				if pane.Command != "" {
+					fmt.Fprintf(&b, " - %s in %s", pane.Command, pane.Path)
+				}
+				if pane.ExitStatus != nil {
+					fmt.Fprintf(&b, " - restarts: %d, last exit status: %d", pane.Restarts, *pane.ExitStatus)
+				}
+				b.WriteString("\n")
+			}
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (s WindowStatus) problems() (result []string) {
+	if s.Missing {
+		result = append(result, "missing")
+	}
+	if s.OutOfOrder {
+		result = append(result, "out of order")
+	}
+	if s.RenamedFrom != "" {
+		result = append(result, fmt.Sprintf("renamed from %q", s.RenamedFrom))
+	}
+	return
+}
+
+func (s PaneStatus) problems() (result []string) {
+	if s.Missing {
+		result = append(result, "missing")
+	}
+	if s.Extra {
+		result = append(result, "extra")
+	}
+	if s.Exited {
+		result = append(result, "exited")
+	}
+	if s.CommandsChanged {
+		result = append(result, "commands changed")
+	}
+	return
+}
+
+func parenthesize(descriptions []string) string {
+	if len(descriptions) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(descriptions, ", ") + ")"
+}
+
+func WriteStatusJSON(w io.Writer, statuses []ProjectStatus) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(statuses)
+}
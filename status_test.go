@@ -0,0 +1,91 @@
+package main_test
+
+import (
+	"bytes"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+
+	. "github.com/stroiman/muxify"
+)
+
+func statusCLI(t *testing.T) (CLI, *MockRunner, *bytes.Buffer) {
+	fakeOs := FakeOS{
+		files: fstest.MapFS{"/users/foo/.config/muxify/projects.yaml": &fstest.MapFile{
+			Data: []byte(configuration),
+		}},
+		env: map[string]string{"HOME": "/users/foo"},
+	}
+	mock := NewMockRunner(gomock.NewController(t))
+	var stdout bytes.Buffer
+	return CLI{Runner: mock, OS: fakeOs, Stdout: &stdout}, mock, &stdout
+}
+
+func TestStatusWithoutDrift(t *testing.T) {
+	cli, mock, stdout := statusCLI(t)
+	mock.EXPECT().GetRunningSessions(gomock.Any()).Return(TmuxSessions{
+		{Name: "Project 1"}, {Name: "Project 1@feature"}, {Name: "Other@feature"},
+	}, nil)
+	mock.EXPECT().GetProjectStatus(gomock.Any(), projectNamed("Project 1")).Return(ProjectStatus{
+		Name:    "Project 1",
+		Running: true,
+		Windows: []WindowStatus{{Name: "Editor", Panes: []PaneStatus{
+			{Task: "editor", Command: "nvim", Path: "/src"},
+		}}},
+	}, nil)
+	mock.EXPECT().GetProjectStatus(gomock.Any(), projectNamed("Project 1@feature")).Return(ProjectStatus{
+		Name:    "Project 1@feature",
+		Running: true,
+	}, nil)
+	mock.EXPECT().GetProjectStatus(gomock.Any(), projectNamed("Project 2")).Return(ProjectStatus{
+		Name: "Project 2",
+	}, nil)
+	assert.NoError(t, cli.Run([]string{"muxify", "status"}))
+	assert.Equal(t, "Project 1\n"+
+		"  Editor\n"+
+		"    editor - nvim in /src\n"+
+		"Project 1@feature\n"+
+		"Project 2: not running\n", stdout.String())
+}
+
+func TestStatusWithDrift(t *testing.T) {
+	cli, mock, stdout := statusCLI(t)
+	exitStatus := 1
+	mock.EXPECT().GetProjectStatus(gomock.Any(), projectNamed("Project 1")).Return(ProjectStatus{
+		Name:    "Project 1",
+		Running: true,
+		Windows: []WindowStatus{
+			{Name: "Editor", Panes: []PaneStatus{
+				{Task: "editor", Command: "sh", Path: "/src", Exited: true, CommandsChanged: true},
+				{Task: "server", Command: "node", Path: "/src", Restarts: 2, ExitStatus: &exitStatus},
+			}},
+			{Name: "Logs", Missing: true, Panes: []PaneStatus{{Task: "logs", Missing: true}}},
+			{Name: "Code", RenamedFrom: "Editor 2"},
+		},
+	}, nil)
+	assert.Equal(t, ErrDrift, cli.Run([]string{"muxify", "status", "Project 1"}))
+	assert.Equal(t, "Project 1\n"+
+		"  Editor\n"+
+		"    editor (exited, commands changed) - sh in /src\n"+
+		"    server - node in /src - restarts: 2, last exit status: 1\n"+
+		"  Logs (missing)\n"+
+		"    logs (missing)\n"+
+		"  Code (renamed from \"Editor 2\")\n", stdout.String())
+}
+
+func TestStatusAsJSON(t *testing.T) {
+	cli, mock, stdout := statusCLI(t)
+	mock.EXPECT().GetProjectStatus(gomock.Any(), projectNamed("Project 2")).Return(ProjectStatus{
+		Name:    "Project 2",
+		Running: true,
+		Windows: []WindowStatus{{Name: "Editor", OutOfOrder: true}},
+	}, nil)
+	assert.Equal(t, ErrDrift, cli.Run([]string{"muxify", "status", "--json", "Project 2"}))
+	assert.JSONEq(t, `[{
+		"name": "Project 2",
+		"running": true,
+		"windows": [{"name": "Editor", "out_of_order": true}]
+	}]`, stdout.String())
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RestartPolicy decides if a task is restarted when its process exits. Tasks
+// with a restart policy run their commands as the process of the pane, rather
+// than in a shell, and the pane remains when the process exits, showing that
+// the task died.
+type RestartPolicy string
+
+const (
+	RestartNever     RestartPolicy = "never"
+	RestartOnFailure RestartPolicy = "on-failure"
+	RestartAlways    RestartPolicy = "always"
+)
+
+var restartPolicies = []RestartPolicy{RestartNever, RestartOnFailure, RestartAlways}
+
+func (p *RestartPolicy) UnmarshalYAML(node *yaml.Node) error {
+	policy := RestartPolicy(node.Value)
+	if !slices.Contains(restartPolicies, policy) {
+		return fmt.Errorf("line %d: Invalid restart policy %q. Valid policies are %v",
+			node.Line, node.Value, restartPolicies)
+	}
+	*p = policy
+	return nil
+}
+
+func (p RestartPolicy) shouldRestart(exitStatus int) bool {
+	return p == RestartAlways || (p == RestartOnFailure && exitStatus != 0)
+}
+
+// Pane options storing the state of supervised tasks
+const (
+	restartPolicyOption = "@muxify_restart"
+	restartsOption      = "@muxify_restarts"
+	failuresOption      = "@muxify_failures"
+	startedOption       = "@muxify_started"
+	exitStatusOption    = "@muxify_exit_status"
+)
+
+// Restarts are delayed by minRestartDelay, doubled for each consecutive
+// failure, up to maxRestartDelay. A task running for longer than
+// backoffResetAfter is no longer considered failing.
+const (
+	minRestartDelay   = 500 * time.Millisecond
+	maxRestartDelay   = 30 * time.Second
+	backoffResetAfter = time.Minute
+)
+
+func restartDelay(failures int) time.Duration {
+	if failures >= 6 {
+		return maxRestartDelay
+	}
+	return min(minRestartDelay<<failures, maxRestartDelay)
+}
+
+// startSupervised adds commands to the batch, starting the task's commands as
+// the process of the pane, replacing any running process. Without a dir, the
+// process starts in the pane's original working directory.
+func (p TmuxPane) startSupervised(batch *TmuxBatch, task Task, dir string) {
+	options := [][2]string{
+		{"remain-on-exit", "on"},
+		{restartPolicyOption, string(task.Restart)},
+		{restartsOption, "0"},
+		{failuresOption, "0"},
+		{startedOption, strconv.FormatInt(time.Now().Unix(), 10)},
+	}
+	for _, option := range options {
+		batch.Add(p.optionArgs("set-option", paneScope, option[0], option[1])...)
+	}
+	args := []string{"respawn-pane", "-k", "-t", p.Id}
+	if dir != "" {
+		args = append(args, "-c", dir)
+	}
+	commands := task.Commands
+	if task.Exec != "" {
+		commands = Commands{task.paneCommand()}
+	} else if task.Shell != "" && len(commands) > 0 {
+		commands = Commands{task.Shell + " -c " + shellQuote(strings.Join(commands, "\n"))}
+	}
+	if len(commands) > 0 {
+		args = append(args, supervisedCommand(commands))
+	}
+	batch.Add(args...)
+}
+
+// supervisedCommand returns a shell command running the commands, which
+// records the exit status in a pane option, as tmux doesn't always know the
+// exit status of a process.
+func supervisedCommand(commands Commands) string {
+	return fmt.Sprintf(
+		"(\n%s\n)\nstatus=$?\ntmux set-option -p -t \"$TMUX_PANE\" %s \"$status\"\nexit \"$status\"",
+		strings.Join(commands, "\n"),
+		exitStatusOption,
+	)
+}
+
+// reconcileSupervisor installs the pane-died hook for the session, if the
+// project has tasks with a restart policy.
+func (p Project) reconcileSupervisor(ctx context.Context, server TmuxServer, session TmuxSession) error {
+	for _, task := range p.Tasks {
+		if task.Restart != "" {
+			command := fmt.Sprintf("%s __pane-died #{q:socket_path} #{pane_id}",
+				shellQuote(server.executable()))
+			return server.Command(ctx, "set-hook", "-t", session.Id, "pane-died",
+				"run-shell -b "+tmuxQuote(command)).Run()
+		}
+	}
+	return server.Command(ctx, "set-hook", "-u", "-t", session.Id, "pane-died").Run()
+}
+
+// tmuxQuote quotes a string as a single argument in a tmux command
+func tmuxQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`).Replace(s) + `"`
+}
+
+// unknownExitStatus is the exit status of a process, which exited without
+// muxify or tmux knowing the status, treated as a failure.
+const unknownExitStatus = -1
+
+// SupervisorState is the state of a supervised task's pane
+type SupervisorState struct {
+	Policy     RestartPolicy
+	Dead       bool
+	Restarts   int
+	Failures   int
+	ExitStatus int
+	Started    time.Time
+}
+
+func (p TmuxPane) supervisorState(ctx context.Context) (state SupervisorState, err error) {
+	fields, err := p.displayFormat(ctx, tmuxFormat{
+		field("pane_dead"),
+		field("pane_dead_status"),
+		field("pane_dead_signal"),
+		field(restartPolicyOption),
+		field(restartsOption),
+		field(failuresOption),
+		field(startedOption),
+		field(exitStatusOption),
+	})
+	if err != nil {
+		return
+	}
+	number := func(s string) int {
+		n, _ := strconv.Atoi(s)
+		return n
+	}
+	state.Dead = fields[0] == "1"
+	state.Policy = RestartPolicy(fields[3])
+	state.Restarts = number(fields[4])
+	state.Failures = number(fields[5])
+	state.Started = time.Unix(int64(number(fields[6])), 0)
+	state.ExitStatus = number(fields[7])
+	if state.Dead {
+		switch {
+		case fields[1] != "":
+			state.ExitStatus = number(fields[1])
+		case fields[2] != "":
+			// Killed by a signal, reported like a shell does
+			state.ExitStatus = 128 + number(fields[2])
+		case fields[7] == "":
+			state.ExitStatus = unknownExitStatus
+		}
+	}
+	return
+}
+
+// HandlePaneDied is called from the pane-died hook, recording the exit status
+// of the pane's process, and restarting it after a delay, depending on the
+// restart policy.
+func (s TmuxServer) HandlePaneDied(ctx context.Context, paneId string) error {
+	pane := TmuxPane{TmuxTarget: TmuxTarget{s, paneId}}
+	state, err := pane.supervisorState(ctx)
+	if err != nil || state.Policy == "" {
+		return err
+	}
+	err = pane.setOption(ctx, paneScope, exitStatusOption, strconv.Itoa(state.ExitStatus))
+	if err != nil || !state.Policy.shouldRestart(state.ExitStatus) {
+		return err
+	}
+	if time.Since(state.Started) > backoffResetAfter {
+		state.Failures = 0
+	}
+	select {
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	case <-time.After(restartDelay(state.Failures)):
+	}
+	// The pane may have been restarted, or killed, while waiting
+	if current, err := pane.supervisorState(ctx); err != nil || !current.Dead {
+		return err
+	}
+	options := [][2]string{
+		{restartsOption, strconv.Itoa(state.Restarts + 1)},
+		{failuresOption, strconv.Itoa(state.Failures + 1)},
+		{startedOption, strconv.FormatInt(time.Now().Unix(), 10)},
+	}
+	for _, option := range options {
+		if err = pane.setOption(ctx, paneScope, option[0], option[1]); err != nil {
+			return err
+		}
+	}
+	return pane.Command(ctx, "respawn-pane", "-t", paneId).Run()
+}
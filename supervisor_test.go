@@ -0,0 +1,31 @@
+package main_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	. "github.com/stroiman/muxify"
+)
+
+func TestDecodeRestartPolicy(t *testing.T) {
+	config, err := Decode(strings.NewReader(`projects:
+  - name: web
+    tasks:
+      server:
+        restart: on-failure
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, RestartOnFailure, config.Projects[0].Tasks["server"].Restart)
+}
+
+func TestInvalidRestartPolicy(t *testing.T) {
+	_, err := Decode(strings.NewReader(`projects:
+  - name: web
+    tasks:
+      server:
+        restart: sometimes
+`))
+	assert.ErrorContains(t, err, `Invalid restart policy "sometimes"`)
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// findTaskPane returns the pane running the task in the project's session
+func (p Project) findTaskPane(
+	ctx context.Context,
+	server TmuxServer,
+	taskId TaskId,
+) (pane TmuxPane, err error) {
+	sessions, err := server.GetRunningSessions(ctx)
+	if err != nil {
+		return
+	}
+	session, ok := TmuxSessions(sessions).FindByName(p.Name)
+	if !ok {
+		return pane, fmt.Errorf("Project %q is not running", p.Name)
+	}
+	windows, err := session.GetWindows(ctx)
+	for _, window := range windows {
+		if err != nil {
+			return
+		}
+		var panes TmuxPanes
+		panes, err = window.GetPanes(ctx)
+		if found := panes.FindByTitle(taskId); found != nil {
+			return *found, nil
+		}
+	}
+	if err == nil {
+		err = fmt.Errorf("Task %q is not running in project %q", taskId, p.Name)
+	}
+	return
+}
+
+// SendToTask runs a shell command in the pane of the task.
+func (p Project) SendToTask(
+	ctx context.Context,
+	server TmuxServer,
+	taskId TaskId,
+	command string,
+) error {
+	pane, err := p.findTaskPane(ctx, server, taskId)
+	if err == nil {
+		err = pane.RunShellCommand(ctx, command)
+	}
+	return err
+}
+
+// RestartTask kills the process running in the pane of the task, and runs the
+// task's commands in a new shell, or as the pane's process if the task has a
+// restart policy.
+func (p Project) RestartTask(ctx context.Context, server TmuxServer, taskId TaskId) error {
+	task, ok := p.Tasks[taskId]
+	if !ok {
+		return fmt.Errorf("Project %q has no task %q", p.Name, taskId)
+	}
+	task, err := task.compilePrompt()
+	if err != nil {
+		return fmt.Errorf("Task %q: %w", taskId, err)
+	}
+	pane, err := p.findTaskPane(ctx, server, taskId)
+	if err != nil {
+		return err
+	}
+	batch := pane.Batch()
+	batch.Add(pane.optionArgs("set-option", paneScope, commandsOption, task.signature())...)
+	if task.Restart != "" {
+		pane.startSupervised(batch, task, "")
+		return batch.Run(ctx)
+	}
+	if task.paneCommand() == "" {
+		batch.Add("respawn-pane", "-k", "-t", pane.Id)
+	}
+	input := server.paneInput()
+	if err = pane.startTask(ctx, batch, input, task, "", nil, false); err != nil {
+		return err
+	}
+	if err = batch.Run(ctx); err != nil {
+		return err
+	}
+	return input.send(ctx)
+}
+
+// paneCommand returns the shell command replacing the pane's default shell, if
+// the task has a program or a shell.
+func (t Task) paneCommand() string {
+	if t.Exec != "" && t.Shell != "" {
+		return t.Shell + " -c " + shellQuote(t.Exec)
+	}
+	if t.Exec != "" {
+		return t.Exec
+	}
+	return t.Shell
+}
+
+// signature encodes how the task is started as a single line option value, to
+// detect changes to the configuration of running tasks.
+func (t Task) signature() string {
+	if t.Exec == "" && t.Shell == "" {
+		return commandsSignature(t.Commands)
+	}
+	return commandsSignature(append(slices.Clone(t.Commands), "exec: "+t.Exec, "shell: "+t.Shell))
+}
+
+// startTask adds commands to the batch starting the task in a pane, and adds
+// the task's commands to the input typed into the pane once it's ready. The
+// pane's process is replaced with the task's program or shell, or a shell
+// replaying the saved history, unless the pane was launched running the task's
+// program or shell, which keeps the pane open until then. Without a dir, the
+// process starts in the pane's original working directory.
+func (p TmuxPane) startTask(
+	ctx context.Context,
+	batch *TmuxBatch,
+	input *paneInput,
+	task Task,
+	dir string,
+	saved *SavedPane,
+	launched bool,
+) error {
+	var command string
+	if !launched {
+		if task.KeepOpen {
+			batch.Add(p.optionArgs("set-option", paneScope, "remain-on-exit", "on")...)
+		}
+		command = task.paneCommand()
+	}
+	if saved != nil && task.Exec == "" {
+		shell := task.Shell
+		if shell == "" {
+			var err error
+			if shell, err = p.defaultCommand(ctx); err != nil {
+				return err
+			}
+		}
+		command = saved.replayCommand(shell)
+		dir = saved.restoreDir(task, dir)
+	}
+	if command != "" {
+		args := []string{"respawn-pane", "-k", "-t", p.Id}
+		if dir != "" {
+			args = append(args, "-c", dir)
+		}
+		batch.Add(append(args, command)...)
+	}
+	if launched && !task.KeepOpen {
+		// The pane was kept open until the task is set up, and closes if its
+		// program has already exited
+		batch.Add(p.optionArgs("set-option", paneScope, "-u", "remain-on-exit")...)
+		batch.Add("if-shell", "-F", "-t", p.Id, "#{pane_dead}", "kill-pane -t "+p.Id)
+	}
+	for _, command := range task.Commands {
+		input.add(p, task, p.shellCommandArgs(command)...)
+	}
+	return nil
+}
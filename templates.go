@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+)
+
+// Template is a reusable set of windows and tasks that a project can extend.
+// Parameters in the template, e.g. `{{.Package}}`, are substituted with the
+// values from the project's `params`, falling back to the template's defaults.
+type Template struct {
+	Extends          string            `yaml:"extends,omitempty"`
+	WorkingDirectory string            `yaml:"working_dir,omitempty"`
+	Params           map[string]string `yaml:"params,omitempty"`
+	Windows          []Window
+	Tasks            map[string]Task
+}
+
+// flatten merges a template with the templates it extends, the most specific
+// template taking precedence.
+func (c MuxifyConfiguration) flatten(name string, seen []string) (Template, error) {
+	if slices.Contains(seen, name) {
+		return Template{}, fmt.Errorf("Circular template inheritance: %v", append(seen, name))
+	}
+	template, ok := c.Templates[name]
+	if !ok {
+		return Template{}, fmt.Errorf("Template %q not found", name)
+	}
+	if template.Extends == "" {
+		return template, nil
+	}
+	base, err := c.flatten(template.Extends, append(seen, name))
+	if err != nil {
+		return Template{}, err
+	}
+	return Template{
+		WorkingDirectory: firstNonEmpty(template.WorkingDirectory, base.WorkingDirectory),
+		Params:           mergeMaps(base.Params, template.Params),
+		Windows:          mergeWindows(base.Windows, template.Windows),
+		Tasks:            mergeMaps(base.Tasks, template.Tasks),
+	}, nil
+}
+
+// applyTemplate returns the project with the windows and tasks of the template
+// it extends. Windows and tasks defined in the project itself replace those of
+// the same name in the template.
+func (c MuxifyConfiguration) applyTemplate(p Project) (Project, error) {
+	if p.Extends == "" {
+		return p, nil
+	}
+	template, err := c.flatten(p.Extends, nil)
+	if err != nil {
+		return p, err
+	}
+	template, err = template.substitute(mergeMaps(template.Params, p.Params))
+	if err != nil {
+		return p, err
+	}
+	p.WorkingDirectory = firstNonEmpty(p.WorkingDirectory, template.WorkingDirectory)
+	p.Windows = mergeWindows(template.Windows, p.Windows)
+	p.Tasks = mergeMaps(template.Tasks, p.Tasks)
+	return p, nil
+}
+
+// substitute returns a copy of the template with parameters replaced in all
+// task and window fields.
+func (t Template) substitute(params map[string]string) (result Template, err error) {
+	result.Params = params
+	if result.WorkingDirectory, err = substituteParams(t.WorkingDirectory, params); err != nil {
+		return
+	}
+	if t.Tasks != nil {
+		result.Tasks = make(map[string]Task, len(t.Tasks))
+	}
+	for name, task := range t.Tasks {
+		if result.Tasks[name], err = task.substitute(params); err != nil {
+			return result, fmt.Errorf("Task %q: %w", name, err)
+		}
+	}
+	result.Windows = make([]Window, len(t.Windows))
+	for i, window := range t.Windows {
+		if result.Windows[i], err = window.substitute(params); err != nil {
+			return result, fmt.Errorf("Window %q: %w", window.Name, err)
+		}
+	}
+	return
+}
+
+func (t Task) substitute(params map[string]string) (result Task, err error) {
+	result = t
+	if result.WorkingDirectory, err = substituteParams(t.WorkingDirectory, params); err != nil {
+		return
+	}
+	if result.Exec, err = substituteParams(t.Exec, params); err != nil {
+		return
+	}
+	if result.Shell, err = substituteParams(t.Shell, params); err != nil {
+		return
+	}
+	result.Commands, err = substituteAll(t.Commands, params)
+	return
+}
+
+func (w Window) substitute(params map[string]string) (result Window, err error) {
+	result = w
+	if result.Name, err = substituteParams(w.Name, params); err != nil {
+		return
+	}
+	if result.WorkingDirectory, err = substituteParams(w.WorkingDirectory, params); err != nil {
+		return
+	}
+	result.Panes, err = substituteAll(w.Panes, params)
+	return
+}
+
+func substituteAll(values []string, params map[string]string) ([]string, error) {
+	if values == nil {
+		return nil, nil
+	}
+	result := make([]string, len(values))
+	for i, value := range values {
+		var err error
+		if result[i], err = substituteParams(value, params); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+var paramExp = regexp.MustCompile(`\{\{\s*\.(\w+)\s*\}\}`)
+
+// substituteParams replaces `{{.Name}}` with the value of the parameter. Only
+// this simple form is recognised, leaving anything else untouched, so commands
+// can still contain e.g. a `--format '{{.Names}}'` argument for docker, as long
+// as it doesn't clash with a parameter name. The parameters of a for_each
+// expansion are left for the expansion to substitute.
+func substituteParams(s string, params map[string]string) (string, error) {
+	var err error
+	result := paramExp.ReplaceAllStringFunc(s, func(match string) string {
+		name := paramExp.FindStringSubmatch(match)[1]
+		value, ok := params[name]
+		if !ok && slices.Contains(forEachParams, name) {
+			return match
+		}
+		if !ok && err == nil {
+			err = fmt.Errorf("Missing parameter %q in %q", name, s)
+		}
+		return value
+	})
+	return result, err
+}
+
+// mergeWindows returns the base windows, where windows in overrides replace a
+// window with the same name, and other windows are appended.
+func mergeWindows(base []Window, overrides []Window) []Window {
+	result := slices.Clone(base)
+	for _, window := range overrides {
+		i := slices.IndexFunc(result, func(w Window) bool { return w.Name == window.Name })
+		if i >= 0 {
+			result[i] = window
+		} else {
+			result = append(result, window)
+		}
+	}
+	return result
+}
+
+func mergeMaps[T any](base map[string]T, overrides map[string]T) map[string]T {
+	if base == nil && overrides == nil {
+		return nil
+	}
+	result := maps.Clone(base)
+	if result == nil {
+		result = make(map[string]T, len(overrides))
+	}
+	maps.Copy(result, overrides)
+	return result
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,164 @@
+package main_test
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/suite"
+
+	. "github.com/stroiman/muxify"
+)
+
+type TemplatesTestSuite struct {
+	GomegaSuite
+}
+
+func TestTemplates(t *testing.T) {
+	suite.Run(t, new(TemplatesTestSuite))
+}
+
+var templateConfig = `
+templates:
+  base:
+    tasks:
+      editor:
+        commands: [nvim .]
+    windows:
+      - name: Editor
+        panes: [editor]
+  go-service:
+    extends: base
+    params:
+      Package: "."
+    tasks:
+      test:
+        working_dir: "{{.Package}}"
+        commands: ["gow test ./{{ .Package }}/..."]
+      server:
+        commands: ["go run ./cmd/{{.Package}}"]
+    windows:
+      - name: Editor
+        layout: vertical
+        panes: [editor, test]
+      - name: Server
+        working_dir: "cmd/{{.Package}}"
+        panes: [server]
+projects:
+  - name: api
+    working_dir: /src/api
+    extends: go-service
+    params:
+      Package: api
+  - name: web
+    working_dir: /src/web
+    extends: go-service
+    tasks:
+      editor:
+        commands: [code .]
+    windows:
+      - name: Editor
+        panes: [editor]
+      - name: Storybook
+        panes: [storybook]
+`
+
+func (s *TemplatesTestSuite) decode(config string) MuxifyConfiguration {
+	result, err := Decode(strings.NewReader(config))
+	s.Expect(err).ToNot(HaveOccurred())
+	return result
+}
+
+func (s *TemplatesTestSuite) TestProjectGetsTasksFromTemplateChain() {
+	project, _ := s.decode(templateConfig).GetProject("api")
+	s.Expect(project.TaskNames()).To(HaveExactElements("editor", "server", "test"))
+	s.Expect(project.Tasks["editor"].Commands).To(HaveExactElements("nvim ."))
+}
+
+func (s *TemplatesTestSuite) TestParametersAreSubstituted() {
+	project, _ := s.decode(templateConfig).GetProject("api")
+	s.Expect(project.Tasks["test"].WorkingDirectory).To(Equal("api"))
+	s.Expect(project.Tasks["test"].Commands).To(HaveExactElements("gow test ./api/..."))
+	s.Expect(project.Tasks["server"].Commands).To(HaveExactElements("go run ./cmd/api"))
+}
+
+func (s *TemplatesTestSuite) TestWindowWorkingDirParameterIsSubstituted() {
+	project, _ := s.decode(templateConfig).GetProject("api")
+	s.Expect(project.Windows).To(ContainElement(
+		And(HaveField("Name", "Server"), HaveField("WorkingDirectory", "cmd/api"))))
+}
+
+func (s *TemplatesTestSuite) TestExecAndShellParametersAreSubstituted() {
+	project, _ := s.decode(`
+templates:
+  debugger:
+    params:
+      Shell: sh
+    tasks:
+      debug:
+        exec: "dlv debug ./cmd/{{.Package}}"
+        shell: "/bin/{{.Shell}}"
+projects:
+  - name: api
+    extends: debugger
+    params:
+      Package: api
+`).GetProject("api")
+	s.Expect(project.Tasks["debug"]).To(And(
+		HaveField("Exec", "dlv debug ./cmd/api"),
+		HaveField("Shell", "/bin/sh"),
+	))
+}
+
+func (s *TemplatesTestSuite) TestParameterDefaultFromTemplate() {
+	project, _ := s.decode(templateConfig).GetProject("web")
+	s.Expect(project.Tasks["test"].Commands).To(HaveExactElements("gow test ././..."))
+}
+
+func (s *TemplatesTestSuite) TestDerivedTemplateOverridesWindows() {
+	project, _ := s.decode(templateConfig).GetProject("api")
+	s.Expect(project.Windows).To(HaveExactElements(
+		And(HaveField("Name", "Editor"), HaveField("Panes", []string{"editor", "test"})),
+		HaveField("Name", "Server"),
+	))
+	s.Expect(project.Validate()).To(Succeed())
+}
+
+func (s *TemplatesTestSuite) TestProjectOverridesTasksAndWindows() {
+	project, _ := s.decode(templateConfig).GetProject("web")
+	s.Expect(project.Tasks["editor"].Commands).To(HaveExactElements("code ."))
+	s.Expect(project.Windows).To(HaveExactElements(
+		And(HaveField("Name", "Editor"), HaveField("Panes", []string{"editor"})),
+		HaveField("Name", "Server"),
+		HaveField("Name", "Storybook"),
+	))
+}
+
+func (s *TemplatesTestSuite) TestMissingParameterIsAnError() {
+	_, err := Decode(strings.NewReader(`
+templates:
+  t:
+    tasks:
+      test:
+        commands: ["go test ./{{.Package}}"]
+projects:
+  - name: p
+    extends: t`))
+	s.Expect(err).To(MatchError(ContainSubstring("Package")))
+}
+
+func (s *TemplatesTestSuite) TestUnknownTemplateIsAnError() {
+	_, err := Decode(strings.NewReader("projects:\n  - name: p\n    extends: missing"))
+	s.Expect(err).To(MatchError(ContainSubstring("missing")))
+}
+
+func (s *TemplatesTestSuite) TestCircularTemplatesIsAnError() {
+	_, err := Decode(strings.NewReader(`
+templates:
+  a: {extends: b}
+  b: {extends: a}
+projects:
+  - name: p
+    extends: a`))
+	s.Expect(err).To(MatchError(ContainSubstring("Circular")))
+}
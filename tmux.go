@@ -1,13 +1,16 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log/slog"
+	"os"
 	"os/exec"
-	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 )
 
 func must(err error) {
@@ -50,46 +53,92 @@ func getLines(output []byte) []string {
 
 type CmdExt struct {
 	*exec.Cmd
+	// retry returns a new command for the same tmux command and context, as a
+	// command only runs once.
+	retry func() *exec.Cmd
+	// cause returns why the context of the command is done, or nil.
+	cause func() error
+}
+
+// serverExitingAttempts is the number of times a command is attempted when the
+// server exits while the command runs. tmux exits the server asynchronously when
+// the last session is killed, and a command connecting in the meantime fails.
+const serverExitingAttempts = 5
+
+// killedCommandWaitDelay is how long to wait for the output of a tmux client
+// killed by a cancelled context. tmux passes the client's stdout to the server,
+// so the output isn't closed when the client is killed if the server is stuck.
+const killedCommandWaitDelay = 500 * time.Millisecond
+
+// Output runs the command, retrying if the command connected to a server that
+// was exiting.
+func (c CmdExt) Output() ([]byte, error) {
+	cmd := c.Cmd
+	for attempt := 1; ; attempt++ {
+		output, err := cmd.Output()
+		if cause := c.cause(); err != nil && cause != nil {
+			// The command was killed, report why
+			return output, cause
+		}
+		if attempt == serverExitingAttempts || !isServerExitingError(err) {
+			return output, err
+		}
+		slog.Debug("tmux server exiting, retrying command", "args", cmd.Args)
+		time.Sleep(time.Duration(attempt) * 10 * time.Millisecond)
+		retry := c.retry()
+		retry.Dir, retry.Env = cmd.Dir, cmd.Env
+		cmd = retry
+	}
 }
 
-func (c CmdExt) MustOutput() []byte {
-	o, err := c.Output()
-	if err != nil {
-		panic(err)
+// Run runs the command, retrying like Output, unless the command's output is
+// redirected, e.g., when attaching.
+func (c CmdExt) Run() error {
+	if c.Stdout != nil || c.Stderr != nil {
+		return c.Cmd.Run()
 	}
-	return o
+	_, err := c.Output()
+	return err
 }
 
-func parseLineQuoted(line string) ([]string, error) {
-	if line[0] != '"' || line[len(line)-1] != '"' {
-		return nil, fmt.Errorf("Bad result from tmux: %s", line)
+func tmuxStderr(err error) string {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return string(exitErr.Stderr)
 	}
-	return strings.Split(string(line[1:len(line)-1]), `":"`), nil
+	return ""
 }
 
-func parseLinesQuoted(output []byte) (result [][]string, err error) {
-	lines := getLines(output)
-	result = make([][]string, len(lines))
-	for i, line := range lines {
-		if result[i], err = parseLineQuoted(line); err != nil {
-			return nil, err
-		}
-	}
-	return result, nil
+func isServerExitingError(err error) bool {
+	return strings.Contains(tmuxStderr(err), "server exited unexpectedly")
+}
+
+// isNoServerError returns whether the command failed because no server is
+// running on the socket, i.e., the socket refused the connection, or doesn't
+// exist. Other errors connecting, e.g., lacking permission, are reported.
+func isNoServerError(err error) bool {
+	stderr := tmuxStderr(err)
+	return strings.Contains(stderr, "no server running") ||
+		strings.Contains(stderr, "error connecting to") &&
+			strings.Contains(stderr, "(No such file or directory)")
 }
 
-var dimensionsParser = regexp.MustCompile(`^(\d+),(\d+),(\d+),(\d+)$`)
+func (c CmdExt) MustOutput() []byte {
+	o, err := c.Output()
+	if err != nil {
+		panic(err)
+	}
+	return o
+}
 
-func parseDimensions(input string) (PaneLayout, error) {
-	submatch := dimensionsParser.FindStringSubmatch(input)
-	if submatch == nil || len(submatch) != 5 {
-		return PaneLayout{}, fmt.Errorf("Bad dimensions result: %s", input)
+func parseDimensions(fields []string) (layout PaneLayout, err error) {
+	dimensions := make([]int, len(fields))
+	for i, field := range fields {
+		if dimensions[i], err = strconv.Atoi(field); err != nil {
+			return PaneLayout{}, fmt.Errorf("Bad dimensions result: %q", fields)
+		}
 	}
-	top, _ := strconv.Atoi(submatch[1])
-	bottom, _ := strconv.Atoi(submatch[2])
-	left, _ := strconv.Atoi(submatch[3])
-	right, _ := strconv.Atoi(submatch[4])
-	return PaneLayout{top, bottom, left, right}, nil
+	return PaneLayout{dimensions[0], dimensions[1], dimensions[2], dimensions[3]}, nil
 }
 
 /* -------- TmuxServer -------- */
@@ -97,19 +146,55 @@ func parseDimensions(input string) (PaneLayout, error) {
 type TmuxServer struct {
 	ControlMode bool
 	SocketName  string
-	ConfigFile  string
+	// SocketPath is the path of the server socket, used instead of SocketName
+	// when set, e.g., when tmux runs muxify from a hook
+	SocketPath string
+	ConfigFile string
+	// Executable is the muxify executable tmux runs from hooks and key
+	// bindings. Defaults to the running executable.
+	Executable string
+	// LockDir is the directory of lock files preventing concurrent
+	// reconciliation of a session. Defaults to $XDG_RUNTIME_DIR, or the temp
+	// dir.
+	LockDir string
+	// LockTimeout is how long to wait for another process reconciling the same
+	// session. Defaults to 30 seconds.
+	LockTimeout time.Duration
+}
+
+func (s TmuxServer) executable() string {
+	if s.Executable != "" {
+		return s.Executable
+	}
+	if executable, err := os.Executable(); err == nil {
+		return executable
+	}
+	return "muxify"
+}
+
+func (s TmuxServer) KillServer(ctx context.Context) error {
+	return s.Command(ctx, "kill-server").Run()
 }
 
-func (s TmuxServer) KillServer() error {
-	return s.Command("kill-server").Run()
+func (s TmuxServer) Command(ctx context.Context, arg ...string) CmdExt {
+	return s.command(ctx, nil, arg...)
 }
 
-func (s TmuxServer) Command(arg ...string) CmdExt {
-	c := make([]string, 0)
+// formatCommand returns a command printing a tmuxFormat. The -u flag makes tmux
+// output the separators between fields as they are; tmux replaces control
+// characters with underscores unless the client's locale is UTF-8.
+func (s TmuxServer) formatCommand(ctx context.Context, arg ...string) CmdExt {
+	return s.command(ctx, []string{"-u"}, arg...)
+}
+
+func (s TmuxServer) command(ctx context.Context, flags []string, arg ...string) CmdExt {
+	c := slices.Clone(flags)
 	if s.ControlMode {
 		c = append(c, "-C")
 	}
-	if s.SocketName != "" {
+	if s.SocketPath != "" {
+		c = append(c, "-S", s.SocketPath)
+	} else if s.SocketName != "" {
 		c = append(c, "-L", s.SocketName)
 	}
 	if s.ConfigFile != "" {
@@ -117,13 +202,21 @@ func (s TmuxServer) Command(arg ...string) CmdExt {
 	}
 	c = append(c, arg...)
 	slog.Debug("Running tmux command", "options", c)
-	cmd := exec.Command("tmux", c...)
-	return CmdExt{cmd}
+	newCmd := func() *exec.Cmd {
+		cmd := exec.CommandContext(ctx, "tmux", c...)
+		cmd.WaitDelay = killedCommandWaitDelay
+		return cmd
+	}
+	return CmdExt{newCmd(), newCmd, func() error { return context.Cause(ctx) }}
 }
 
-func (server TmuxServer) StartSession(name string, arg ...string) (TmuxSession, error) {
+func (server TmuxServer) StartSession(
+	ctx context.Context,
+	name string,
+	arg ...string,
+) (TmuxSession, error) {
 	c := append([]string{"new-session", "-F", "#{session_id}", "-P", "-d"}, arg...)
-	out, err := server.Command(c...).Output()
+	out, err := server.Command(ctx, c...).Output()
 	if err != nil {
 		return TmuxSession{}, err
 	} else {
@@ -137,25 +230,32 @@ func (server TmuxServer) StartSession(name string, arg ...string) (TmuxSession,
 	}
 }
 
-func (s TmuxServer) StartSessionByNameInDir(name string, dir string) (TmuxSession, error) {
-	return s.StartSession(name, "-s", name, "-c", dir)
+func (s TmuxServer) StartSessionByName(ctx context.Context, name string) (TmuxSession, error) {
+	return s.StartSession(ctx, name, "-s", tmuxLiteral(name))
 }
 
-func (s TmuxServer) StartSessionByName(name string) (TmuxSession, error) {
-	return s.StartSession(name, "-s", name)
+// StartSessionWithWindow starts a session, creating its first window from the
+// spec.
+func (s TmuxServer) StartSessionWithWindow(
+	ctx context.Context,
+	name string,
+	window WindowSpec,
+) (TmuxSession, error) {
+	return s.StartSession(ctx, name, append([]string{"-s", tmuxLiteral(name)}, window.args()...)...)
 }
 
-func (s TmuxServer) GetRunningSessions() ([]TmuxSession, error) {
-	stdOut, err := s.Command("start", ";", "list-sessions", "-F", `"#{session_id}":"#{session_name}"`).
-		Output()
+func (s TmuxServer) GetRunningSessions(ctx context.Context) ([]TmuxSession, error) {
+	lines, err := s.query(
+		ctx,
+		tmuxFormat{field("session_id"), encodedField("session_name")},
+		"list-sessions",
+	)
+	if isNoServerError(err) {
+		return nil, nil
+	}
 	if err != nil {
-		exitErr, ok := err.(*exec.ExitError)
-		if ok {
-			fmt.Println("Exit error!!\n", string(exitErr.Stderr))
-		}
 		return nil, err
 	}
-	lines, err := parseLinesQuoted(stdOut)
 	result := make([]TmuxSession, len(lines))
 	for i, line := range lines {
 		result[i] = TmuxSession{
@@ -169,20 +269,68 @@ func (s TmuxServer) GetRunningSessions() ([]TmuxSession, error) {
 	return result, err
 }
 
-func (s TmuxServer) KillSession(session TmuxSession) error {
+// DisplayMessage shows a message in the status line of all attached clients
+// until a key is pressed.
+func (s TmuxServer) DisplayMessage(ctx context.Context, message string) error {
+	output, err := s.Command(ctx, "list-clients", "-F", "#{client_name}").Output()
+	if err != nil {
+		return err
+	}
+	// The message is a format, so "#" must be escaped
+	message = tmuxLiteral(message)
+	for _, client := range getLines(output) {
+		err = s.Command(ctx, "display-message", "-c", client, "-d", "0", "--", message).Run()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Attach attaches the terminal to the session, or switches the client to the
+// session when running inside tmux.
+func (s TmuxServer) Attach(ctx context.Context, sessionName string) error {
+	if _, insideTmux := os.LookupEnv("TMUX"); insideTmux {
+		return s.Command(ctx, "switch-client", "-t", "="+tmuxSessionName(sessionName)).Run()
+	}
+	cmd := s.Command(ctx, "attach-session", "-t", "="+tmuxSessionName(sessionName))
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// ErrSessionNotRunning is returned when killing a session that isn't running
+var ErrSessionNotRunning = errors.New("Session is not running")
+
+func (s TmuxServer) KillSessionByName(ctx context.Context, sessionName string) error {
+	err := s.Command(ctx, "kill-session", "-t", "="+tmuxSessionName(sessionName)).Run()
+	if isNoServerError(err) || strings.Contains(tmuxStderr(err), "can't find session") {
+		return fmt.Errorf("%w: %s", ErrSessionNotRunning, sessionName)
+	}
+	return err
+}
+
+func (s TmuxServer) KillSession(ctx context.Context, session TmuxSession) error {
 	if session.Id == "" {
 		panic("Trying to kill a session with no id")
 	}
-	return s.Command("kill-session", "-t", session.Id).Run()
+	return s.Command(ctx, "kill-session", "-t", session.Id).Run()
 }
 
 /* -------- TmuxSessions -------- */
 
 type TmuxSessions []TmuxSession
 
+// tmuxSessionName returns the name tmux gives a session created with the name,
+// as tmux replaces colons and periods, which are used in target names.
+func tmuxSessionName(name string) string {
+	return strings.NewReplacer(":", "_", ".", "_").Replace(name)
+}
+
 func (s TmuxSessions) FindByName(name string) (session TmuxSession, ok bool) {
 	for _, session := range s {
-		if session.Name == name {
+		if session.Name == tmuxSessionName(name) {
 			return session, true
 		}
 	}
@@ -196,28 +344,20 @@ type TmuxTarget struct {
 	Id string
 }
 
-func (s TmuxTarget) runCommandAndParseOutputFormat(command ...string) ([][]string, error) {
-	output, err := s.Command(command...).
-		Output()
-	if err != nil {
-		return nil, err
-	}
-	return parseLinesQuoted(output)
-}
-
-func (s TmuxTarget) GetPanes() (panes TmuxPanes, err error) {
-	data, err := s.runCommandAndParseOutputFormat(
-		"list-panes",
-		"-t",
-		s.Id,
-		"-F",
-		`"#{pane_id}":"#{pane_title}":"#{pane_top},#{pane_bottom},#{pane_left},#{pane_right}"`,
-	)
+func (s TmuxTarget) GetPanes(ctx context.Context) (panes TmuxPanes, err error) {
+	data, err := s.query(ctx, tmuxFormat{
+		field("pane_id"),
+		field("pane_title"),
+		field("pane_top"),
+		field("pane_bottom"),
+		field("pane_left"),
+		field("pane_right"),
+	}, "list-panes", "-t", s.Id)
 	panes = make([]TmuxPane, len(data))
 	for i, line := range data {
 		if err == nil {
 			var layout PaneLayout
-			layout, err = parseDimensions(line[2])
+			layout, err = parseDimensions(line[2:])
 			panes[i] = TmuxPane{
 				TmuxTarget{
 					s.TmuxServer,
@@ -232,37 +372,37 @@ func (s TmuxTarget) GetPanes() (panes TmuxPanes, err error) {
 	return
 }
 
-func (s TmuxTarget) MustGetPanes() TmuxPanes {
-	panes, err := s.GetPanes()
+func (s TmuxTarget) MustGetPanes(ctx context.Context) TmuxPanes {
+	panes, err := s.GetPanes(ctx)
 	must(err)
 	return panes
 }
 
-func (s TmuxServer) GetCurrentWindowIndexForSession(session TmuxSession) (res int, err error) {
-	// output, err = s.Command("list-windows", "-t", session.Id, "-F", `"#{window_id}":"#{window_name}"`).
-	var output []byte
-	output, err = s.Command("list-windows",
-		"-f", "#{==:#{window_index},#{active_window_index}}", "-F", "#{window_index}").Output()
+func (s TmuxServer) GetCurrentWindowIndexForSession(
+	ctx context.Context,
+	session TmuxSession,
+) (int, error) {
+	index, err := session.displayVariable(ctx, "window_index")
 	if err != nil {
-		return
-	}
-	lines := getLines(output)
-	if len(lines) != 1 {
-		err = fmt.Errorf("Unexpected result from tmux command, %v", lines)
-	} else {
-		res, err = strconv.Atoi(lines[0])
-	}
-	return
-}
-
-func (s TmuxServer) GetWindowsForSession(session TmuxSession) (windows TmuxWindows, err error) {
-	var output []byte
-	output, err = s.Command("list-windows", "-t", session.Id, "-F", `"#{window_id}":"#{window_name}":"#{window_index}"`).
-		Output()
+		return 0, err
+	}
+	return strconv.Atoi(index)
+}
+
+func (s TmuxServer) GetWindowsForSession(
+	ctx context.Context,
+	session TmuxSession,
+) (windows TmuxWindows, err error) {
+	lines, err := s.query(ctx, tmuxFormat{
+		field("window_id"),
+		encodedField("window_name"),
+		field("window_index"),
+		field(forEachWindowOption),
+		field(windowIdOption),
+	}, "list-windows", "-t", session.Id)
 	if err != nil {
 		return
 	}
-	lines, err := parseLinesQuoted(output)
 	windows = make([]TmuxWindow, len(lines))
 	for i, line := range lines {
 		var winIndex int
@@ -270,23 +410,23 @@ func (s TmuxServer) GetWindowsForSession(session TmuxSession) (windows TmuxWindo
 		if err != nil {
 			return
 		}
-		windows[i] = TmuxWindow{TmuxTarget{s, line[0]}, line[1], winIndex}
+		windows[i] = TmuxWindow{TmuxTarget{s, line[0]}, line[1], winIndex, line[3], line[4]}
 	}
 	return
 }
 
-func (s TmuxSession) GetWindows() (TmuxWindows, error) {
-	return s.GetWindowsForSession(s)
+func (s TmuxSession) GetWindows(ctx context.Context) (TmuxWindows, error) {
+	return s.GetWindowsForSession(ctx, s)
 }
 
-func (s TmuxSession) MustGetWindows() TmuxWindows {
-	windows, err := s.GetWindows()
+func (s TmuxSession) MustGetWindows(ctx context.Context) TmuxWindows {
+	windows, err := s.GetWindows(ctx)
 	must(err)
 	return windows
 }
 
-func (s TmuxServer) RenameWindow(windowId string, name string) error {
-	return s.Command("rename-window", "-t", windowId, name).Run()
+func (s TmuxServer) RenameWindow(ctx context.Context, windowId string, name string) error {
+	return s.Command(ctx, "rename-window", "-t", windowId, "--", tmuxLiteral(name)).Run()
 }
 
 // WindowTarget represents how the position of a new TMUX window can be passed
@@ -319,45 +459,113 @@ func (t WindowTarget) createArgs() []string {
 }
 
 func (s TmuxServer) CreateWindow(
+	ctx context.Context,
 	target WindowTarget,
 	name string,
 	workingDir string,
 ) (*TmuxWindow, error) {
-	args := []string{"new-window", "-n", name, "-F", `"#{window_id}":"#{window_index}"`, "-P"}
-	args = append(args, target.createArgs()...)
-	if workingDir != "" {
-		args = append(args, "-c", workingDir)
+	windows, err := s.CreateWindows(ctx, target, WindowSpec{Name: name, WorkingDir: workingDir})
+	if err != nil {
+		return nil, err
+	}
+	return windows[0], nil
+}
+
+// WindowSpec is the name and working dir of a window to create
+type WindowSpec struct {
+	Name       string
+	WorkingDir string
+	// Command is the command run in the window's pane instead of the default
+	// shell, if set
+	Command string
+	// RemainOnExit keeps the window's pane open when its command exits, e.g.,
+	// until the pane is set up
+	RemainOnExit bool
+}
+
+// args returns the arguments of new-window, or new-session, creating the
+// window, followed by commands setting up its pane.
+func (spec WindowSpec) args() []string {
+	var args []string
+	if spec.Name != "" {
+		args = append(args, "-n", tmuxLiteral(spec.Name))
+	}
+	if spec.WorkingDir != "" {
+		args = append(args, "-c", spec.WorkingDir)
 	}
-	output, err1 := s.Command(args...).Output()
-	parameters, err2 := parseLineQuoted(sanitizeOutput(output))
-	winIndex, err3 := strconv.Atoi(parameters[1])
-	window := TmuxWindow{
-		TmuxTarget{
-			s,
-			parameters[0],
-		},
-		name,
-		winIndex,
+	if spec.Command != "" {
+		args = append(args, spec.Command)
 	}
-	return &window, errors.Join(err1, err2, err3)
+	if spec.RemainOnExit {
+		args = append(append(args, ";"), remainOnExitArgs...)
+	}
+	return args
+}
+
+// CreateWindows creates windows in order at the target in a single tmux
+// invocation.
+func (s TmuxServer) CreateWindows(
+	ctx context.Context,
+	target WindowTarget,
+	specs ...WindowSpec,
+) ([]*TmuxWindow, error) {
+	format := tmuxFormat{field("window_id"), field("window_index")}
+	// Each window created after the target is placed directly after it, so they
+	// are created in reverse order.
+	order := make([]int, len(specs))
+	for i := range specs {
+		order[i] = i
+		if !target.before {
+			order[i] = len(specs) - 1 - i
+		}
+	}
+	batch := s.Batch()
+	for _, i := range order {
+		args := []string{"new-window", "-F", format.String(), "-P"}
+		args = append(args, target.createArgs()...)
+		batch.Add(append(args, specs[i].args()...)...)
+	}
+	records, err := batch.query(ctx, format)
+	if err == nil && len(records) != len(specs) {
+		err = fmt.Errorf("Bad result from tmux, expected %d windows: %q", len(specs), records)
+	}
+	if err != nil {
+		return nil, err
+	}
+	windows := make([]*TmuxWindow, len(specs))
+	for j, i := range order {
+		winIndex, err := strconv.Atoi(records[j][1])
+		if err != nil {
+			return nil, err
+		}
+		windows[i] = &TmuxWindow{TmuxTarget{s, records[j][0]}, specs[i].Name, winIndex, "", ""}
+	}
+	if !target.before {
+		// Windows created later moved the earlier ones up
+		for i, window := range windows {
+			window.LastKnownIndex = windows[0].LastKnownIndex + i
+		}
+	}
+	return windows, nil
 }
 
 func (s TmuxServer) MoveWindow(
+	ctx context.Context,
 	window *TmuxWindow,
 	target WindowTarget) error {
 	if window.Id == target.target.Id {
 		return nil
 	}
 	if !target.before {
-		movedWinIndex, err1 := window.Index()
-		targetWinIndex, err2 := target.target.Index()
+		movedWinIndex, err1 := window.Index(ctx)
+		targetWinIndex, err2 := target.target.Index(ctx)
 		if movedWinIndex == (targetWinIndex+1) && err1 == nil && err2 == nil {
 			return nil
 		}
 	}
 	args := []string{"move-window", "-s", window.Id}
 	args = append(args, target.createArgs()...)
-	return s.Command(args...).Run()
+	return s.Command(ctx, args...).Run()
 }
 
 type T struct {
@@ -365,36 +573,40 @@ type T struct {
 	PaneTitle  string
 }
 
-func (s TmuxServer) GetWindowAndPaneNames() ([]T, error) {
-	output, err := s.Command(
-		"list-panes",
-		"-a",
-		"-F",
-		`#{window_name}:#{pane_title}`,
-	).Output()
+// GetWindowAndPaneNames returns the names of all panes in the session
+func (s TmuxSession) GetWindowAndPaneNames(ctx context.Context) ([]T, error) {
+	lines, err := s.query(
+		ctx,
+		tmuxFormat{encodedField("window_name"), field("pane_title")},
+		"list-panes", "-s", "-t", s.Id,
+	)
 	if err != nil {
 		return nil, err
 	}
-	lines := getLines(output)
 	result := make([]T, len(lines))
 	for i, line := range lines {
-		parts := strings.Split(line, ":")
-		result[i] = T{parts[0], parts[1]}
+		result[i] = T{line[0], line[1]}
 	}
 	return result, nil
 }
 
-func (s TmuxTarget) RunShellCommand(shellCommand string) error {
-	return s.Command("send-keys", "-t", s.Id, shellCommand+"\n").Run()
+// shellCommandArgs returns the arguments of a command typing the shell command
+// in the target's active pane
+func (s TmuxTarget) shellCommandArgs(shellCommand string) []string {
+	return []string{"send-keys", "-t", s.Id, shellCommand + "\n"}
+}
+
+func (s TmuxTarget) RunShellCommand(ctx context.Context, shellCommand string) error {
+	return s.Command(ctx, s.shellCommandArgs(shellCommand)...).Run()
 }
 
-func (s TmuxTarget) MustRunShellCommand(shellCommand string) {
-	must(s.RunShellCommand(shellCommand))
+func (s TmuxTarget) MustRunShellCommand(ctx context.Context, shellCommand string) {
+	must(s.RunShellCommand(ctx, shellCommand))
 }
 
-func (s TmuxTarget) GetFirstPane() (pane TmuxPane, err error) {
+func (s TmuxTarget) GetFirstPane(ctx context.Context) (pane TmuxPane, err error) {
 	var panes []TmuxPane
-	panes, err = s.GetPanes()
+	panes, err = s.GetPanes(ctx)
 	if len(panes) > 0 {
 		pane = panes[0]
 	}
@@ -409,14 +621,22 @@ type TmuxPane struct {
 	Layout PaneLayout
 }
 
-func (p TmuxPane) Rename(name string) (TmuxPane, error) {
-	err := p.Command("select-pane", "-t", p.Id, "-T", name).Run()
+func (p TmuxPane) renameArgs(name string) []string {
+	return []string{"select-pane", "-t", p.Id, "-T", tmuxLiteral(name)}
+}
+
+func (p TmuxPane) Rename(ctx context.Context, name string) (TmuxPane, error) {
+	err := p.Command(ctx, p.renameArgs(name)...).Run()
 	if err == nil {
 		p.Title = name
 	}
 	return p, err
 }
 
+func (p TmuxPane) Select(ctx context.Context) error {
+	return p.Command(ctx, "select-pane", "-t", p.Id).Run()
+}
+
 /* -------- TmuxPanes -------- */
 
 type TmuxPanes []TmuxPane
@@ -430,57 +650,123 @@ func (p TmuxPanes) FindByTitle(title string) *TmuxPane {
 	return nil
 }
 
+func (p TmuxPanes) FindById(id string) *TmuxPane {
+	for _, pane := range p {
+		if pane.Id == id {
+			return &pane
+		}
+	}
+	return nil
+}
+
 /* -------- TmuxWindow -------- */
 
 type TmuxWindow struct {
 	TmuxTarget
 	Name           string
 	LastKnownIndex int
+	// ExpandedFrom is the window definition the window was expanded from, if
+	// created from a for_each.
+	ExpandedFrom string
+	// StableId is the stable id of the configured window, if configured
+	StableId string
 }
 
-func (w TmuxWindow) Index() (res int, err error) {
-	var output []byte
-	output, err = w.Command("list-windows",
-		"-f", fmt.Sprintf("#{==:#{window_id},%s}", w.Id), "-F", "#{window_index}").Output()
+func (w TmuxWindow) Index(ctx context.Context) (res int, err error) {
+	index, err := w.displayVariable(ctx, "window_index")
 	if err != nil {
 		return
 	}
-	w.LastKnownIndex, err = strconv.Atoi(sanitizeOutput(output))
+	w.LastKnownIndex, err = strconv.Atoi(index)
 	return w.LastKnownIndex, err
 }
 
-func (w TmuxWindow) SplitHorizontal(name string, workingDir string) (TmuxPane, error) {
-	args := []string{"split-window", "-h", "-t", w.Id, "-P", "-F", "#{pane_id}"}
+// paneIdFormat is the format printed when creating panes
+var paneIdFormat = tmuxFormat{field("pane_id")}
+
+// splitArgs returns the arguments of a command splitting the active pane of the
+// window, printing the id of the new pane. Without a command, the new pane
+// runs the default shell.
+func (w TmuxWindow) splitArgs(direction string, workingDir string, command string) []string {
+	args := []string{"split-window", direction, "-t", w.Id, "-P", "-F", paneIdFormat.String()}
 	if workingDir != "" {
 		args = append(args, "-c", workingDir)
 	}
-	output, err := w.Command(args...).
-		Output()
-	paneId := sanitizeOutput(output)
-	pane := TmuxPane{TmuxTarget{w.TmuxServer, paneId}, "", PaneLayout{}}
-	if err == nil {
-		pane, err = pane.Rename(name)
+	if command != "" {
+		args = append(args, command)
 	}
-	return pane, err
+	return args
 }
 
-func (w TmuxWindow) SplitVertical(name string, workingDir string) (TmuxPane, error) {
-	args := []string{"split-window", "-v", "-t", w.Id, "-P", "-F", "#{pane_id}"}
-	if workingDir != "" {
-		args = append(args, "-c", workingDir)
+// remainOnExitArgs are the arguments of a command keeping the pane created by
+// the previous command open when its process exits. A new pane is the target
+// of the following commands in the same tmux invocation, so the option is set
+// before a short-lived process can exit.
+var remainOnExitArgs = []string{"set-option", "-p", "remain-on-exit", "on"}
+
+// createPanes runs a batch of split commands, returning the created panes
+func (w TmuxWindow) createPanes(ctx context.Context, splits *TmuxBatch) (TmuxPanes, error) {
+	records, err := splits.query(ctx, paneIdFormat)
+	if err != nil {
+		return nil, err
 	}
-	output, err := w.Command(args...).
-		Output()
-	paneId := sanitizeOutput(output)
-	pane := TmuxPane{TmuxTarget{w.TmuxServer, paneId}, "", PaneLayout{}}
-	if err == nil {
-		pane, err = pane.Rename(name)
+	panes := make(TmuxPanes, len(records))
+	for i, fields := range records {
+		panes[i] = TmuxPane{TmuxTarget{w.TmuxServer, fields[0]}, "", PaneLayout{}}
 	}
-	return pane, err
+	return panes, nil
 }
 
-func (w TmuxWindow) Select() error {
-	_, err := w.Command("select-window", "-t", w.Id).Output()
+func (w TmuxWindow) split(
+	ctx context.Context,
+	direction string,
+	name string,
+	workingDir string,
+) (TmuxPane, error) {
+	panes, err := w.createPanes(ctx, w.Batch().Add(w.splitArgs(direction, workingDir, "")...))
+	if err != nil {
+		return TmuxPane{}, err
+	}
+	return panes[0].Rename(ctx, name)
+}
+
+func (w TmuxWindow) SplitHorizontal(
+	ctx context.Context,
+	name string,
+	workingDir string,
+) (TmuxPane, error) {
+	return w.split(ctx, "-h", name, workingDir)
+}
+
+func (w TmuxWindow) SplitVertical(
+	ctx context.Context,
+	name string,
+	workingDir string,
+) (TmuxPane, error) {
+	return w.split(ctx, "-v", name, workingDir)
+}
+
+// SetOption sets a window option, e.g., a user option to store muxify state on
+// the window
+func (w TmuxWindow) SetOption(ctx context.Context, name string, value string) error {
+	return w.setOption(ctx, windowScope, name, value)
+}
+
+func (s TmuxServer) KillWindow(ctx context.Context, window TmuxWindow) error {
+	return s.Command(ctx, "kill-window", "-t", window.Id).Run()
+}
+
+func (w TmuxWindow) Select(ctx context.Context) error {
+	_, err := w.Command(ctx, "select-window", "-t", w.Id).Output()
+	return err
+}
+
+// Zoom zooms the active pane of the window, unless it is already zoomed.
+func (w TmuxWindow) Zoom(ctx context.Context) error {
+	zoomed, err := w.displayVariable(ctx, "window_zoomed_flag")
+	if err == nil && zoomed != "1" {
+		err = w.Command(ctx, "resize-pane", "-Z", "-t", w.Id).Run()
+	}
 	return err
 }
 
@@ -496,3 +782,44 @@ func (ws TmuxWindows) FindByName(name string) (TmuxWindow, bool) {
 	}
 	return TmuxWindow{}, false
 }
+
+/* -------- Pane and window state -------- */
+
+// displayFormat returns the fields of the format for the target
+func (t TmuxTarget) displayFormat(ctx context.Context, format tmuxFormat) ([]string, error) {
+	output, err := t.formatCommand(ctx, "display-message", "-p", "-t", t.Id, format.String()).Output()
+	if err != nil {
+		return nil, err
+	}
+	return format.parseOne(output)
+}
+
+func (t TmuxTarget) displayVariable(ctx context.Context, name string) (string, error) {
+	fields, err := t.displayFormat(ctx, tmuxFormat{field(name)})
+	if err != nil {
+		return "", err
+	}
+	return fields[0], nil
+}
+
+// CurrentPath returns the current working directory of the pane's process
+func (p TmuxPane) CurrentPath(ctx context.Context) (string, error) {
+	return p.displayVariable(ctx, "pane_current_path")
+}
+
+// CaptureHistory returns the content of the pane, including the scrollback
+// history.
+func (p TmuxPane) CaptureHistory(ctx context.Context) (string, error) {
+	output, err := p.Command(ctx, "capture-pane", "-p", "-S", "-", "-t", p.Id).Output()
+	return strings.TrimRight(string(output), "\n"), err
+}
+
+// WindowLayout returns the tmux layout string of the window, which can be
+// passed to SelectLayout to recreate the pane sizes.
+func (w TmuxWindow) WindowLayout(ctx context.Context) (string, error) {
+	return w.displayVariable(ctx, "window_layout")
+}
+
+func (w TmuxWindow) SelectLayout(ctx context.Context, layout string) error {
+	return w.Command(ctx, "select-layout", "-t", w.Id, layout).Run()
+}
@@ -0,0 +1,64 @@
+package main
+
+import "context"
+
+// TmuxBatch is a list of tmux commands run in a single tmux invocation, chained
+// with `;`, saving a process for each command. The commands can't depend on
+// the output of each other, and tmux stops at the first failing command.
+type TmuxBatch struct {
+	server   TmuxServer
+	commands [][]string
+}
+
+func (s TmuxServer) Batch() *TmuxBatch {
+	return &TmuxBatch{server: s}
+}
+
+// Add adds a command to the batch. An argument ending in `;` must be escaped
+// with tmuxLiteral, as tmux would treat it as the end of the command.
+func (b *TmuxBatch) Add(arg ...string) *TmuxBatch {
+	b.commands = append(b.commands, arg)
+	return b
+}
+
+func (b *TmuxBatch) Len() int {
+	return len(b.commands)
+}
+
+func (b *TmuxBatch) args() []string {
+	var args []string
+	for i, command := range b.commands {
+		if i > 0 {
+			args = append(args, ";")
+		}
+		args = append(args, command...)
+	}
+	return args
+}
+
+// Run runs the commands, and empties the batch
+func (b *TmuxBatch) Run(ctx context.Context) error {
+	if len(b.commands) == 0 {
+		return nil
+	}
+	defer b.reset()
+	return b.server.Command(ctx, b.args()...).Run()
+}
+
+// query runs commands printing the format, e.g., new-window -P -F, returning
+// the fields printed by each command, and empties the batch.
+func (b *TmuxBatch) query(ctx context.Context, format tmuxFormat) ([][]string, error) {
+	if len(b.commands) == 0 {
+		return nil, nil
+	}
+	defer b.reset()
+	output, err := b.server.formatCommand(ctx, b.args()...).Output()
+	if err != nil {
+		return nil, err
+	}
+	return format.parse(output)
+}
+
+func (b *TmuxBatch) reset() {
+	b.commands = nil
+}
@@ -0,0 +1,148 @@
+package main_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	g "github.com/onsi/gomega"
+	"github.com/stretchr/testify/suite"
+	. "github.com/stroiman/muxify"
+)
+
+type TmuxBatchTestSuite struct {
+	TmuxBaseTestSuite
+	session TmuxSession
+}
+
+func (s *TmuxBatchTestSuite) SetupTest() {
+	ctx := context.Background()
+	s.TmuxBaseTestSuite.SetupTest()
+	var err error
+	s.session, err = s.server.StartSessionByName(ctx, CreateRandomName())
+	s.Expect(err).ToNot(g.HaveOccurred())
+}
+
+func (s *TmuxBatchTestSuite) TearDownTest() {
+	ctx := context.Background()
+	s.server.KillServer(ctx)
+}
+
+func TestTmuxBatch(t *testing.T) {
+	suite.Run(t, new(TmuxBatchTestSuite))
+}
+
+func (s *TmuxBatchTestSuite) windowNames(ctx context.Context) []string {
+	var names []string
+	for _, window := range s.session.MustGetWindows(ctx) {
+		names = append(names, window.Name)
+	}
+	return names
+}
+
+func (s *TmuxBatchTestSuite) TestRunCommands() {
+	ctx := context.Background()
+	window := s.session.MustGetWindows(ctx)[0]
+	batch := s.server.Batch().
+		Add("rename-window", "-t", window.Id, "first").
+		Add("new-window", "-d", "-t", s.session.Id, "-n", "second")
+	s.Expect(batch.Len()).To(g.Equal(2))
+	s.Expect(batch.Run(ctx)).To(g.Succeed())
+	s.Expect(batch.Len()).To(g.Equal(0))
+	s.Expect(s.windowNames(ctx)).To(g.Equal([]string{"first", "second"}))
+}
+
+func (s *TmuxBatchTestSuite) TestStopAtFailingCommand() {
+	ctx := context.Background()
+	batch := s.server.Batch().
+		Add("select-window", "-t", "@999999").
+		Add("new-window", "-d", "-t", s.session.Id, "-n", "second")
+	s.Expect(batch.Run(ctx)).ToNot(g.Succeed())
+	s.Expect(s.session.MustGetWindows(ctx)).To(g.HaveLen(1))
+}
+
+func (s *TmuxBatchTestSuite) expectKnownIndexes(ctx context.Context, windows []*TmuxWindow) {
+	for _, window := range windows {
+		s.Expect(window.Index(ctx)).To(g.Equal(window.LastKnownIndex), window.Name)
+	}
+}
+
+func (s *TmuxBatchTestSuite) TestCreateWindowsInOrder() {
+	ctx := context.Background()
+	first := s.session.MustGetWindows(ctx)[0]
+	s.Expect(s.server.RenameWindow(ctx, first.Id, "first")).To(g.Succeed())
+	after, err := s.server.CreateWindows(ctx, AfterWindow(&first),
+		WindowSpec{Name: "a1"}, WindowSpec{Name: "a2"}, WindowSpec{Name: "a3"})
+	s.Expect(err).ToNot(g.HaveOccurred())
+	s.expectKnownIndexes(ctx, after)
+	before, err := s.server.CreateWindows(ctx, BeforeWindow(&first),
+		WindowSpec{Name: "b1"}, WindowSpec{Name: "b2"})
+	s.Expect(err).ToNot(g.HaveOccurred())
+	s.expectKnownIndexes(ctx, before)
+
+	s.Expect(s.windowNames(ctx)).To(g.Equal([]string{"b1", "b2", "first", "a1", "a2", "a3"}))
+}
+
+func (s *TmuxBatchTestSuite) TestCreateWindowsUnbatched() {
+	ctx := context.Background()
+	useUnbatchedTmux(s.T())
+	first := s.session.MustGetWindows(ctx)[0]
+	s.Expect(s.server.RenameWindow(ctx, first.Id, "first")).To(g.Succeed())
+	after, err := s.server.CreateWindows(ctx, AfterWindow(&first),
+		WindowSpec{Name: "a1"}, WindowSpec{Name: "a2"})
+	s.Expect(err).ToNot(g.HaveOccurred())
+	s.expectKnownIndexes(ctx, after)
+
+	s.Expect(s.windowNames(ctx)).To(g.Equal([]string{"first", "a1", "a2"}))
+}
+
+// createWindows returns the specs of n windows
+func createWindows(n int) []WindowSpec {
+	specs := make([]WindowSpec, n)
+	for i := range specs {
+		specs[i] = WindowSpec{Name: fmt.Sprintf("Window %d", i)}
+	}
+	return specs
+}
+
+func BenchmarkCreate10Windows(b *testing.B) {
+	ctx := context.Background()
+	server := MustCreateTestServer()
+	defer server.KillServer(ctx)
+	specs := createWindows(10)
+	benchmark := func(create func(first *TmuxWindow) error) func(b *testing.B) {
+		return func(b *testing.B) {
+			for range b.N {
+				b.StopTimer()
+				session, err := server.StartSessionByName(ctx, CreateRandomName())
+				if err != nil {
+					b.Fatal(err)
+				}
+				first := session.MustGetWindows(ctx)[0]
+				b.StartTimer()
+				if err = create(&first); err != nil {
+					b.Fatal(err)
+				}
+				b.StopTimer()
+				server.KillSession(ctx, session)
+				b.StartTimer()
+			}
+		}
+	}
+	if _, err := server.StartSessionByName(ctx, "keep server running"); err != nil {
+		b.Fatal(err)
+	}
+	b.Run("one invocation per window", benchmark(func(first *TmuxWindow) (err error) {
+		target := first
+		for _, spec := range specs {
+			if target, err = server.CreateWindow(ctx, AfterWindow(target), spec.Name, ""); err != nil {
+				return
+			}
+		}
+		return
+	}))
+	b.Run("batched", benchmark(func(first *TmuxWindow) error {
+		_, err := server.CreateWindows(ctx, AfterWindow(first), specs...)
+		return err
+	}))
+}
@@ -2,9 +2,11 @@ package main_test
 
 import (
 	"bufio"
+	"context"
 	"io"
 	"os/exec"
 	"regexp"
+	"strings"
 
 	. "github.com/stroiman/muxify"
 )
@@ -33,9 +35,13 @@ type TmuxControl struct {
 	stdout io.ReadCloser
 }
 
-func StartControlMode(server TmuxServer, session TmuxSession) (result TmuxControl, err error) {
+func StartControlMode(
+	ctx context.Context,
+	server TmuxServer,
+	session TmuxSession,
+) (result TmuxControl, err error) {
 	server.ControlMode = true
-	result.cmd = server.Command("attach", "-t", session.Id).Cmd
+	result.cmd = server.Command(ctx, "attach", "-t", session.Id).Cmd
 	result.stdout, err = result.cmd.StdoutPipe()
 	if err != nil {
 		return
@@ -44,12 +50,35 @@ func StartControlMode(server TmuxServer, session TmuxSession) (result TmuxContro
 	if err != nil {
 		return
 	}
-	err = result.cmd.Start()
+	if err = result.cmd.Start(); err == nil {
+		err = result.waitUntilAttached()
+	}
 	return
 }
 
-func MustStartControlMode(server TmuxServer, session TmuxSession) TmuxControl {
-	result, err := StartControlMode(server, session)
+// waitUntilAttached reads the output until the client is attached, so output
+// from the session isn't missed. It reads one byte at a time, leaving the
+// following output for the caller.
+func (c TmuxControl) waitUntilAttached() error {
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		if _, err := c.stdout.Read(b); err != nil {
+			return err
+		}
+		if b[0] != '\n' {
+			line = append(line, b[0])
+			continue
+		}
+		if strings.HasPrefix(string(line), "%session-changed") {
+			return nil
+		}
+		line = line[:0]
+	}
+}
+
+func MustStartControlMode(ctx context.Context, server TmuxServer, session TmuxSession) TmuxControl {
+	result, err := StartControlMode(ctx, server, session)
 	if err != nil {
 		panic(err)
 	}
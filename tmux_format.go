@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Separators of fields and records in the output of tmux format queries. Names
+// never contain control characters, as tmux encodes window and session names
+// using vis(3), and rejects pane titles containing control characters.
+const (
+	fieldSeparator  = "\x1f"
+	recordSeparator = "\x1e"
+)
+
+// formatField is a variable in a tmux format, e.g., window_name
+type formatField struct {
+	name string
+	// encoded is set for variables tmux encodes using vis(3), e.g., window
+	// names, which are decoded when parsing
+	encoded bool
+}
+
+func field(name string) formatField {
+	return formatField{name: name}
+}
+
+func encodedField(name string) formatField {
+	return formatField{name: name, encoded: true}
+}
+
+// tmuxFormat is a format for querying multiple variables of each object, e.g.,
+// window, in a single tmux command, and parsing the output.
+type tmuxFormat []formatField
+
+func (f tmuxFormat) String() string {
+	variables := make([]string, len(f))
+	for i, field := range f {
+		variables[i] = "#{" + field.name + "}"
+	}
+	return strings.Join(variables, fieldSeparator) + recordSeparator
+}
+
+// parse returns the fields of each record in the output of a command using
+// the format
+func (f tmuxFormat) parse(output []byte) ([][]string, error) {
+	var records [][]string
+	rest := strings.TrimPrefix(string(output), "\n")
+	for rest != "" {
+		record, remaining, found := strings.Cut(rest, recordSeparator+"\n")
+		if !found {
+			return nil, fmt.Errorf("Bad result from tmux: %q", rest)
+		}
+		fields := strings.Split(record, fieldSeparator)
+		if len(fields) != len(f) {
+			return nil, fmt.Errorf("Bad result from tmux, expected %d fields: %q", len(f), record)
+		}
+		for i, field := range f {
+			if field.encoded {
+				fields[i] = unvis(fields[i])
+			}
+		}
+		records = append(records, fields)
+		rest = remaining
+	}
+	return records, nil
+}
+
+// parseOne returns the fields in the output of a command printing a single
+// object, e.g., display-message.
+func (f tmuxFormat) parseOne(output []byte) ([]string, error) {
+	records, err := f.parse(output)
+	if err == nil && len(records) != 1 {
+		err = fmt.Errorf("Bad result from tmux, expected one result: %q", output)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return records[0], nil
+}
+
+var cStyleEscapes = map[byte]byte{
+	'a': '\a', 'b': '\b', 'f': '\f', 'n': '\n', 'r': '\r', 't': '\t', 'v': '\v', 's': ' ',
+}
+
+// unvis decodes a string encoded by tmux using vis(3) with octal and C style
+// escapes.
+func unvis(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i == len(s)-1 {
+			b.WriteByte(s[i])
+			continue
+		}
+		next := s[i+1]
+		if next >= '0' && next <= '7' {
+			value, end := 0, i+1
+			for end < len(s) && end < i+4 && s[end] >= '0' && s[end] <= '7' {
+				value = value*8 + int(s[end]-'0')
+				end++
+			}
+			b.WriteByte(byte(value))
+			i = end - 1
+			continue
+		}
+		if decoded, ok := cStyleEscapes[next]; ok {
+			b.WriteByte(decoded)
+		} else {
+			b.WriteByte(next)
+		}
+		i++
+	}
+	return b.String()
+}
+
+// query runs a tmux command listing objects, e.g., list-windows, returning the
+// fields of the format for each object.
+func (s TmuxServer) query(
+	ctx context.Context,
+	format tmuxFormat,
+	command ...string,
+) ([][]string, error) {
+	output, err := s.formatCommand(ctx, append(command, "-F", format.String())...).Output()
+	if err != nil {
+		return nil, err
+	}
+	return format.parse(output)
+}
+
+// tmuxLiteral escapes an argument to a tmux command, e.g., a window name, so it
+// is used literally. tmux expands formats in many arguments, e.g., new-window
+// -n, and treats a trailing ";" as a command separator.
+func tmuxLiteral(arg string) string {
+	arg = strings.ReplaceAll(arg, "#", "##")
+	if strings.HasSuffix(arg, ";") {
+		arg = arg[:len(arg)-1] + `\;`
+	}
+	return arg
+}
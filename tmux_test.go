@@ -1,9 +1,15 @@
 package main_test
 
 import (
+	"context"
 	"fmt"
+	"os"
 	"os/exec"
+	"path"
+	"strings"
 	"testing"
+	"unicode"
+	"unicode/utf8"
 
 	. "github.com/stroiman/muxify"
 
@@ -16,12 +22,44 @@ type TmuxTestSuite struct {
 }
 
 func (s *TmuxTestSuite) TestRunningSessionsWhenServerIsNotStarted() {
+	ctx := context.Background()
 	s.server.SocketName = CreateRandomName()
-	sessions, err := s.server.GetRunningSessions()
+	sessions, err := s.server.GetRunningSessions(ctx)
 	s.Expect(sessions).To(g.BeEmpty())
 	s.Expect(err).ToNot(g.HaveOccurred())
 }
 
+func (s *TmuxTestSuite) TestRunningSessionsWhenSocketCantBeConnected() {
+	ctx := context.Background()
+	file := path.Join(s.T().TempDir(), "file")
+	s.Expect(os.WriteFile(file, nil, 0600)).To(g.Succeed())
+	s.server.SocketPath = path.Join(file, "socket")
+	_, err := s.server.GetRunningSessions(ctx)
+	s.Expect(err).To(g.HaveOccurred())
+}
+
+func (s *TmuxTestSuite) TestRunningSessionsWithoutUTF8Locale() {
+	ctx := context.Background()
+	// tmux assumes UTF-8 when running inside tmux
+	s.T().Setenv("TMUX", "")
+	os.Unsetenv("TMUX")
+	s.T().Setenv("LC_ALL", "C")
+	session, err := s.server.StartSessionByName(ctx, "Project")
+	s.Expect(err).ToNot(g.HaveOccurred())
+	defer s.server.KillServer(ctx)
+	sessions, err := s.server.GetRunningSessions(ctx)
+	s.Expect(err).ToNot(g.HaveOccurred())
+	s.Expect(sessions).To(g.ConsistOf(g.HaveField("Id", session.Id)))
+}
+
+func (s *TmuxTestSuite) TestKillSessionNotRunning() {
+	ctx := context.Background()
+	_, err := s.server.StartSessionByName(ctx, "Project")
+	s.Expect(err).ToNot(g.HaveOccurred())
+	defer s.server.KillServer(ctx)
+	s.Expect(s.server.KillSessionByName(ctx, "Other")).To(g.MatchError(ErrSessionNotRunning))
+}
+
 func TestTmux(t *testing.T) {
 	suite.Run(t, new(TmuxTestSuite))
 }
@@ -32,10 +70,11 @@ type TmuxRunningServerTestSuite struct {
 }
 
 func (s *TmuxRunningServerTestSuite) SetupTest() {
+	ctx := context.Background()
 	s.TmuxBaseTestSuite.SetupTest()
 	fmt.Println("Server", s.server)
 	s.sessionName = CreateRandomName()
-	output, err := s.server.Command("new-session", "-s", s.sessionName, "-d").Output()
+	output, err := s.server.Command(ctx, "new-session", "-s", s.sessionName, "-d").Output()
 	if err != nil {
 		fmt.Println("Error!", string(output), err.Error())
 		if e, ok := err.(*exec.ExitError); ok {
@@ -47,26 +86,29 @@ func (s *TmuxRunningServerTestSuite) SetupTest() {
 }
 
 func (s *TmuxRunningServerTestSuite) TearDownTest() {
-	sessions, err := s.server.GetRunningSessions()
+	ctx := context.Background()
+	sessions, err := s.server.GetRunningSessions(ctx)
 	s.Expect(err).ToNot(g.HaveOccurred())
 	session, ok := TmuxSessions(sessions).FindByName(s.sessionName)
 	if ok {
-		s.Expect(s.server.KillSession(session)).To(g.Succeed())
+		s.Expect(s.server.KillSession(ctx, session)).To(g.Succeed())
 	}
 }
 
 func (s *TmuxRunningServerTestSuite) TestRunningSessionsHasAtLeastOneElement() {
-	result, err := s.server.GetRunningSessions()
+	ctx := context.Background()
+	result, err := s.server.GetRunningSessions(ctx)
 	s.Expect(err).ToNot(g.HaveOccurred())
 	s.Expect(result).To(g.ContainElement(g.HaveField("Name", s.sessionName)))
 }
 
 func (s *TmuxRunningServerTestSuite) TestKillServer() {
-	result, err := s.server.GetRunningSessions()
+	ctx := context.Background()
+	result, err := s.server.GetRunningSessions(ctx)
 	s.Expect(err).ToNot(g.HaveOccurred())
 	session, _ := TmuxSessions(result).FindByName(s.sessionName)
-	s.Expect(s.server.KillSession(session)).To(g.Succeed())
-	result, err = s.server.GetRunningSessions()
+	s.Expect(s.server.KillSession(ctx, session)).To(g.Succeed())
+	result, err = s.server.GetRunningSessions(ctx)
 	s.Expect(err).ToNot(g.HaveOccurred())
 	s.Expect(result).ToNot(g.ContainElement(g.HaveField("Name", s.sessionName)))
 }
@@ -74,3 +116,100 @@ func (s *TmuxRunningServerTestSuite) TestKillServer() {
 func TestTmuxRunningServer(t *testing.T) {
 	suite.Run(t, new(TmuxRunningServerTestSuite))
 }
+
+func (s *TmuxRunningServerTestSuite) TestNamesWithSeparatorCharacters() {
+	ctx := context.Background()
+	name := "web: \"client\"\t\\ 1"
+	title := `api: "server" \ #1`
+	sessions, err := s.server.GetRunningSessions(ctx)
+	s.Expect(err).ToNot(g.HaveOccurred())
+	session, _ := TmuxSessions(sessions).FindByName(s.sessionName)
+	window := session.MustGetWindows(ctx)[0]
+	s.Expect(s.server.RenameWindow(ctx, window.Id, name)).To(g.Succeed())
+	_, err = window.MustGetPanes(ctx)[0].Rename(ctx, title)
+	s.Expect(err).ToNot(g.HaveOccurred())
+
+	s.Expect(session.MustGetWindows(ctx)[0].Name).To(g.Equal(name))
+	s.Expect(window.MustGetPanes(ctx)[0].Title).To(g.Equal(title))
+	names, err := session.GetWindowAndPaneNames(ctx)
+	s.Expect(err).ToNot(g.HaveOccurred())
+	s.Expect(names).To(g.ContainElement(T{WindowName: name, PaneTitle: title}))
+}
+
+func (s *TmuxRunningServerTestSuite) TestSessionNameWithColonAndPeriod() {
+	ctx := context.Background()
+	name := "api.v2: " + CreateRandomName()
+	session, err := s.server.StartSessionByName(ctx, name)
+	s.Expect(err).ToNot(g.HaveOccurred())
+	defer s.server.KillSession(ctx, session)
+	sessions, err := s.server.GetRunningSessions(ctx)
+	s.Expect(err).ToNot(g.HaveOccurred())
+	found, ok := TmuxSessions(sessions).FindByName(name)
+	s.Expect(ok).To(g.BeTrue())
+	s.Expect(found.Id).To(g.Equal(session.Id))
+}
+
+// startFuzzSession starts a session on a new server, which is killed when the
+// fuzz test is done.
+func startFuzzSession(ctx context.Context, f *testing.F) TmuxSession {
+	server := MustCreateTestServer()
+	session, err := server.StartSessionByName(ctx, CreateRandomName())
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Cleanup(func() { server.Command(ctx, "kill-server").Run() })
+	return session
+}
+
+func FuzzWindowNames(f *testing.F) {
+	ctx := context.Background()
+	for _, name := range []string{
+		"", "web", "a:b", `"quoted":"name"`, `back\slash`, `\\`, `\012`, "tab\tnew\nline",
+		"\x1f\x1e", "emoji 🚀", ":", `"`, "#{session_name}", "##", ";", `a\;`, "-n",
+	} {
+		f.Add(name)
+	}
+	session := startFuzzSession(ctx, f)
+	window := session.MustGetWindows(ctx)[0]
+	f.Fuzz(func(t *testing.T, name string) {
+		if !utf8.ValidString(name) || strings.ContainsRune(name, 0) {
+			t.Skip("tmux doesn't preserve invalid UTF-8 and null characters")
+		}
+		if err := session.RenameWindow(ctx, window.Id, name); err != nil {
+			t.Fatal(err)
+		}
+		windows, err := session.GetWindows(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if windows[0].Name != name {
+			t.Errorf("Window name %q was read as %q", name, windows[0].Name)
+		}
+	})
+}
+
+func FuzzPaneTitles(f *testing.F) {
+	ctx := context.Background()
+	for _, title := range []string{
+		"", "web", "a:b", `"quoted":"title"`, `back\slash`, `\012`, "emoji 🚀", ":", "#{pane_id}",
+	} {
+		f.Add(title)
+	}
+	session := startFuzzSession(ctx, f)
+	window := session.MustGetWindows(ctx)[0]
+	f.Fuzz(func(t *testing.T, title string) {
+		if !utf8.ValidString(title) || strings.ContainsFunc(title, func(r rune) bool { return !unicode.IsGraphic(r) }) {
+			t.Skip("tmux rejects titles with control characters and invalid UTF-8")
+		}
+		if _, err := window.MustGetPanes(ctx)[0].Rename(ctx, title); err != nil {
+			t.Fatal(err)
+		}
+		panes, err := window.GetPanes(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if panes[0].Title != title {
+			t.Errorf("Pane title %q was read as %q", title, panes[0].Title)
+		}
+	})
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// worktreeSeparator separates the project name from the branch in the session
+// name of a project started in a git worktree.
+const worktreeSeparator = "@"
+
+// WorktreeSessionName returns the name of the session for a project started in
+// the worktree of a branch.
+func WorktreeSessionName(projectName string, branch string) string {
+	return projectName + worktreeSeparator + branch
+}
+
+// ParentProjectName returns the name of the project, a worktree session was
+// started from, or false if the session isn't a worktree session.
+func ParentProjectName(sessionName string) (string, bool) {
+	name, _, found := splitWorktreeSessionName(sessionName)
+	return name, found
+}
+
+// splitWorktreeSessionName splits the name of a worktree session into the
+// project name and the branch. Branches can't contain the separator, so the
+// project name can.
+func splitWorktreeSessionName(sessionName string) (projectName, branch string, found bool) {
+	i := strings.LastIndex(sessionName, worktreeSeparator)
+	if i < 0 {
+		return sessionName, "", false
+	}
+	return sessionName[:i], sessionName[i+len(worktreeSeparator):], true
+}
+
+// sessionProjectName returns the name of the project running in the session,
+// and the branch of a worktree session. As project names can contain the
+// separator, a project named like the session takes precedence over a worktree
+// session of another project.
+func (c MuxifyConfiguration) sessionProjectName(sessionName string) (projectName, branch string, isWorktree bool) {
+	if _, ok := c.GetProject(sessionName); ok {
+		return sessionName, "", false
+	}
+	return splitWorktreeSessionName(sessionName)
+}
+
+// ForWorktree returns the project to start in a separate session for a git
+// worktree of the branch. When the project is resolved, the working directory
+// is rebased on the path of the worktree, so `{{project.dir}}` refers to the
+// worktree.
+func (p Project) ForWorktree(branch string) (Project, error) {
+	if p.WorkingDirectory == "" {
+		return p, errors.New("A project must have a working_dir to use worktrees")
+	}
+	if strings.Contains(branch, worktreeSeparator) {
+		return p, fmt.Errorf("Branch %q: worktree branches can't contain %q", branch, worktreeSeparator)
+	}
+	p.Name = WorktreeSessionName(p.Name, branch)
+	p.worktree = branch
+	return p, nil
+}
+
+// worktreeDir returns the working dir rebased on the path of the worktree of
+// the branch, which is created from the branch if it doesn't exist. A new
+// worktree is placed next to the main worktree, e.g. "../muxify@feature-x".
+func worktreeDir(dir string, branch string) (string, error) {
+	workingDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", err
+	}
+	topLevel, err := git(workingDir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", err
+	}
+	relDir, err := filepath.Rel(topLevel, workingDir)
+	if err != nil {
+		return "", err
+	}
+	worktreeDir, found, err := findWorktree(topLevel, branch)
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		worktreeDir = filepath.Join(
+			filepath.Dir(topLevel),
+			filepath.Base(topLevel)+worktreeSeparator+strings.ReplaceAll(branch, "/", "-"),
+		)
+		if err = addWorktree(topLevel, worktreeDir, branch); err != nil {
+			return "", err
+		}
+	}
+	return filepath.Join(worktreeDir, relDir), nil
+}
+
+// findWorktree returns the path of an existing worktree having the branch
+// checked out.
+func findWorktree(repoDir string, branch string) (dir string, found bool, err error) {
+	output, err := git(repoDir, "worktree", "list", "--porcelain")
+	if err != nil {
+		return
+	}
+	for _, line := range strings.Split(output, "\n") {
+		if worktree, ok := strings.CutPrefix(line, "worktree "); ok {
+			dir = worktree
+		}
+		if line == "branch refs/heads/"+branch {
+			return dir, true, nil
+		}
+	}
+	return "", false, nil
+}
+
+func addWorktree(repoDir string, worktreeDir string, branch string) error {
+	_, err := git(repoDir, "show-ref", "--verify", "--quiet", "refs/heads/"+branch)
+	if err == nil {
+		_, err = git(repoDir, "worktree", "add", worktreeDir, branch)
+	} else {
+		_, err = git(repoDir, "worktree", "add", "-b", branch, worktreeDir)
+	}
+	return err
+}
+
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	output, err := cmd.Output()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		err = fmt.Errorf("git %s: %s", strings.Join(args, " "), strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	return sanitizeOutput(output), err
+}
@@ -0,0 +1,110 @@
+package main_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/suite"
+
+	. "github.com/stroiman/muxify"
+)
+
+type WorktreeTestSuite struct {
+	GomegaSuite
+	repo string
+}
+
+func TestWorktree(t *testing.T) {
+	suite.Run(t, new(WorktreeTestSuite))
+}
+
+func (s *WorktreeTestSuite) git(args ...string) {
+	cmd := exec.Command("git", append([]string{"-C", s.repo}, args...)...)
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=muxify", "GIT_AUTHOR_EMAIL=muxify@example.com",
+		"GIT_COMMITTER_NAME=muxify", "GIT_COMMITTER_EMAIL=muxify@example.com",
+	)
+	output, err := cmd.CombinedOutput()
+	s.Expect(err).ToNot(HaveOccurred(), string(output))
+}
+
+func (s *WorktreeTestSuite) SetupTest() {
+	s.GomegaSuite.SetupTest()
+	dir, err := filepath.EvalSymlinks(s.T().TempDir())
+	s.Expect(err).ToNot(HaveOccurred())
+	s.repo = filepath.Join(dir, "repo")
+	s.Expect(os.MkdirAll(filepath.Join(s.repo, "backend"), 0700)).To(Succeed())
+	s.git("init", "-q")
+	s.git("commit", "-q", "--allow-empty", "-m", "Initial commit")
+}
+
+// startInWorktree returns the resolved project for the worktree of the branch
+func (s *WorktreeTestSuite) startInWorktree(project Project, branch string) (Project, error) {
+	project, err := project.ForWorktree(branch)
+	if err != nil {
+		return project, err
+	}
+	return project.Resolve()
+}
+
+func (s *WorktreeTestSuite) TestCreateWorktreeForNewBranch() {
+	project := Project{Name: "api", WorkingDirectory: filepath.Join(s.repo, "backend")}
+	worktreeProject, err := s.startInWorktree(project, "feature/x")
+	s.Expect(err).ToNot(HaveOccurred())
+	s.Expect(worktreeProject.Name).To(Equal("api@feature/x"))
+	s.Expect(worktreeProject.WorkingDirectory).To(
+		Equal(filepath.Join(filepath.Dir(s.repo), "repo@feature-x", "backend")))
+	s.Expect(filepath.Join(filepath.Dir(s.repo), "repo@feature-x")).To(BeADirectory())
+}
+
+func (s *WorktreeTestSuite) TestCreateWorktreeForExistingBranch() {
+	s.git("branch", "feature-y")
+	project := Project{Name: "api", WorkingDirectory: s.repo}
+	worktreeProject, err := s.startInWorktree(project, "feature-y")
+	s.Expect(err).ToNot(HaveOccurred())
+	s.Expect(worktreeProject.WorkingDirectory).To(
+		Equal(filepath.Join(filepath.Dir(s.repo), "repo@feature-y")))
+}
+
+func (s *WorktreeTestSuite) TestReuseExistingWorktree() {
+	existing := filepath.Join(filepath.Dir(s.repo), "elsewhere")
+	s.git("worktree", "add", "-q", "-b", "feature-z", existing)
+	project := Project{Name: "api", WorkingDirectory: s.repo}
+	worktreeProject, err := s.startInWorktree(project, "feature-z")
+	s.Expect(err).ToNot(HaveOccurred())
+	s.Expect(worktreeProject.WorkingDirectory).To(Equal(existing))
+}
+
+func (s *WorktreeTestSuite) TestProjectDirIsTheWorktree() {
+	project := Project{
+		Name:             "api",
+		WorkingDirectory: s.repo,
+		Tasks:            map[string]Task{"build": {Commands: []string{"cd {{project.dir}}"}}},
+	}
+	worktreeProject, err := s.startInWorktree(project, "feature-w")
+	s.Expect(err).ToNot(HaveOccurred())
+	s.Expect(worktreeProject.Tasks["build"].Commands).To(HaveExactElements(
+		"cd " + filepath.Join(filepath.Dir(s.repo), "repo@feature-w")))
+}
+
+func (s *WorktreeTestSuite) TestProjectWithoutWorkingDir() {
+	_, err := Project{Name: "api"}.ForWorktree("feature-x")
+	s.Expect(err).To(HaveOccurred())
+}
+
+func (s *WorktreeTestSuite) TestBranchWithSeparator() {
+	project := Project{Name: "api", WorkingDirectory: s.repo}
+	_, err := project.ForWorktree("feature@x")
+	s.Expect(err).To(MatchError(ContainSubstring("can't contain")))
+}
+
+func (s *WorktreeTestSuite) TestParentProjectName() {
+	parent, ok := ParentProjectName("me@work@feature-x")
+	s.Expect(ok).To(BeTrue())
+	s.Expect(parent).To(Equal("me@work"))
+	_, ok = ParentProjectName("api")
+	s.Expect(ok).To(BeFalse())
+}